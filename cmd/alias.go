@@ -0,0 +1,44 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+)
+
+// aliasCmd represents the alias command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage domain aliases of existing websites",
+	Long: `Add or remove additional domains served by a website. The website's Caddyfile is rendered again from its template,
+the whole Caddy configuration is validated and Caddy is reloaded. If validation fails, the previous Caddyfile is kept.`,
+}
+
+// changeAliases applies modify to the aliases of the registered site, then renders, validates and reloads its config.
+func changeAliases(domainArgument string, modify func(registry *structs.SiteRegistry, record structs.SiteRecord) ([]string, error)) {
+	changeSite(domainArgument, func(registry *structs.SiteRegistry, record structs.SiteRecord, site *structs.SiteConfig) error {
+		aliases, err := modify(registry, record)
+		site.Aliases = aliases
+
+		return err
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(aliasCmd)
+}
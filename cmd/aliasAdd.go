@@ -0,0 +1,52 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// aliasAddCmd represents the alias add command
+var aliasAddCmd = &cobra.Command{
+	Use:   "add <domain name> <alias>...",
+	Short: "Add domain aliases to a website",
+	Long:  `Add domains served by the website, besides its own domain. An alias can't be served by another registered website.`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeAliases(args[0], func(registry *structs.SiteRegistry, record structs.SiteRecord) ([]string, error) {
+			for _, alias := range args[1:] {
+				ascii, _, err := utils.NormalizeDomain(alias)
+				if err != nil {
+					return nil, fmt.Errorf("alias %s: %w", alias, err)
+				}
+
+				if owner, ok := registry.Owner(ascii); ok && owner != record.DomainName {
+					return nil, fmt.Errorf("%s is already served by %s", ascii, owner)
+				}
+			}
+
+			return normalizeAliases(record.DomainName, append(record.Aliases, args[1:]...))
+		})
+	},
+}
+
+func init() {
+	aliasCmd.AddCommand(aliasAddCmd)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// aliasRemoveCmd represents the alias remove command
+var aliasRemoveCmd = &cobra.Command{
+	Use:   "remove <domain name> <alias>...",
+	Short: "Remove domain aliases from a website",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeAliases(args[0], func(registry *structs.SiteRegistry, record structs.SiteRecord) ([]string, error) {
+			remove := map[string]bool{}
+
+			for _, alias := range args[1:] {
+				ascii, _, err := utils.NormalizeDomain(alias)
+				if err != nil {
+					return nil, fmt.Errorf("alias %s: %w", alias, err)
+				}
+
+				remove[ascii] = true
+			}
+
+			var kept []string
+			for _, alias := range record.Aliases {
+				if remove[alias] {
+					delete(remove, alias)
+				} else {
+					kept = append(kept, alias)
+				}
+			}
+
+			for alias := range remove {
+				return nil, fmt.Errorf("%s is not an alias of %s", alias, record.DomainName)
+			}
+
+			return kept, nil
+		})
+	},
+}
+
+func init() {
+	aliasCmd.AddCommand(aliasRemoveCmd)
+}
@@ -0,0 +1,383 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var (
+	applyFile   string
+	applyPrune  bool
+	applyDryRun bool
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply -f <sites manifest>",
+	Short: "Bring websites to the state declared in a manifest",
+	Long: `Compare websites declared in a YAML manifest with the existing ones (Caddyfiles in sites-all, symlinks in sites-enabled,
+directories and databases), then create missing websites and update changed ones. Declared websites with a Caddyfile in sites-all,
+but missing from the sites registry (i.e. created by hand), are reported as conflicts and left untouched. With --prune, websites
+missing from the manifest are disabled and their Caddyfiles, php-fpm pools and systemd units removed, including unregistered ones. Caddy is reloaded once, after all changes.
+
+Example manifest:
+  sites:
+    - domain: example.com
+      type: php
+      database:
+        type: mysql
+    - domain: app.example.com
+      type: app
+      port: 8081
+      vars:
+        upstream_timeout: 30s`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		manifest, err := structs.ReadSitesManifest(applyFile)
+		if err != nil {
+			println(fmt.Sprintf("Could not read sites manifest %s: %s", applyFile, err.Error()))
+			os.Exit(ExitUsage)
+		}
+
+		registry := loadRegistry(envConfig)
+
+		unregistered, err := registry.Unregistered(envConfig)
+		if err != nil {
+			println(fmt.Sprintf("Could not list Caddyfiles in sites-all: %s", err.Error()))
+			os.Exit(ExitFileStructure)
+		}
+
+		conflicts := map[string]bool{}
+		for _, domain := range unregistered {
+			conflicts[domain] = true
+		}
+
+		changed, failed := false, 0
+		declared := map[string]bool{}
+
+		for _, entry := range manifest.Sites {
+			declared[entry.Domain] = true
+
+			if conflicts[entry.Domain] {
+				println(fmt.Sprintf("[%s] Conflict: Caddyfile exists in sites-all, but the website is not registered. Remove the Caddyfile to let apply create the website", entry.Domain))
+				failed++
+				continue
+			}
+
+			siteChanged, err := applySite(envConfig, registry, entry)
+			if err != nil {
+				println(fmt.Sprintf("[%s] Failed: %s", entry.Domain, err.Error()))
+				failed++
+			}
+
+			changed = changed || siteChanged
+		}
+
+		if applyPrune {
+			for _, domain := range registry.Domains() {
+				if declared[domain] {
+					continue
+				}
+
+				if err := pruneSite(envConfig, registry.Sites[domain]); err != nil {
+					println(fmt.Sprintf("[%s] Failed to remove: %s", domain, err.Error()))
+					failed++
+				}
+
+				changed = true
+			}
+
+			for _, domain := range unregistered {
+				if declared[domain] {
+					continue
+				}
+
+				if err := pruneUnregistered(envConfig, domain); err != nil {
+					println(fmt.Sprintf("[%s] Failed to remove: %s", domain, err.Error()))
+					failed++
+				}
+
+				changed = true
+			}
+		}
+
+		if !changed && failed == 0 {
+			println("All websites are up to date")
+		} else if changed && !applyDryRun {
+			if ok, err := (structs.SiteConfig{}).ReloadCaddy(envConfig); !ok {
+				println(err.Error())
+				os.Exit(ExitCaddyReload)
+			}
+		}
+
+		if failed > 0 {
+			os.Exit(ExitPartialFailure)
+		}
+	},
+}
+
+// applySite creates or updates a single site, returning whether anything had to change.
+func applySite(envConfig utils.EnvironmentConfig, registry *structs.SiteRegistry, entry structs.SiteManifestEntry) (bool, error) {
+	desired := entry.SiteConfig()
+	if err := desired.ApplyTypeDefaults(); err != nil {
+		return false, err
+	}
+
+	var database *databaseRequest
+	if entry.Database != nil {
+		database = &databaseRequest{
+			Type:     utils.GetDatabaseType(entry.Database.Type),
+			Host:     entry.Database.Host,
+			Name:     entry.Database.Name,
+			User:     entry.Database.User,
+			UserHost: entry.Database.UserHost,
+		}
+	}
+
+	record, err := registry.Get(desired.DomainName)
+	exists := err == nil
+
+	// Applications without a declared port keep the one they have, or get a free one
+	isApp := desired.Type.Base() == utils.ProgramTypeApp
+	if isApp && desired.Port == 0 && len(desired.Upstreams) == 0 {
+		if exists && record.Port > 0 {
+			desired.Port = record.Port
+		} else if !applyDryRun {
+			if desired.Port, err = sitePort(envConfig, PortAuto); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if !exists {
+		println(fmt.Sprintf("[%s] Website does not exist and will be created", desired.DomainName))
+		if applyDryRun {
+			return true, nil
+		}
+
+		_, err = provisionSite(envConfig, &desired, database)
+
+		return true, err
+	}
+
+	site := structs.SiteConfigFromRecord(record)
+	changed := false
+
+	if site.ForceBase != desired.ForceBase {
+		println(fmt.Sprintf("Warning: [%s] basedomain setting of an existing website cannot be changed, keeping the current directory structure", site.DomainName))
+	}
+
+	names := desired.Aliases
+	if counterpart := desired.WwwCounterpart(); len(counterpart) > 0 {
+		names = append([]string{counterpart}, names...)
+	}
+
+	for _, name := range names {
+		if owner, ok := registry.Owner(name); ok && owner != site.DomainName {
+			return false, withExitCode(ExitUsage, fmt.Errorf("%s is already served by %s", name, owner))
+		}
+	}
+
+	if site.Type != desired.Type {
+		println(fmt.Sprintf("[%s] Website is %s and will become %s", site.DomainName, strings.ToLower(string(site.Type)), strings.ToLower(string(desired.Type))))
+		changed = true
+	}
+
+	site.Type, site.Port, site.Vars = desired.Type, desired.Port, desired.Vars
+	site.Aliases, site.Www = desired.Aliases, desired.Www
+	site.Upstreams, site.LbPolicy = desired.Upstreams, desired.LbPolicy
+	site.HealthPath, site.HealthEvery = desired.HealthPath, desired.HealthEvery
+
+	if _, err = os.Stat(site.FilesRoot()); os.IsNotExist(err) {
+		println(fmt.Sprintf("[%s] Directory structure is missing and will be created", site.DomainName))
+		changed = true
+
+		if !applyDryRun {
+			if err = createSiteStructure(envConfig, &site); err != nil {
+				return changed, err
+			}
+		}
+	}
+
+	// A new type gets its php-fpm pool or systemd unit like in updateSite, undone if its config is rejected
+	var undo rollback
+	previous := site
+	if site.Type != record.Type && !applyDryRun {
+		if previous, err = switchSiteRuntime(envConfig, &site, &undo); err != nil {
+			undo.run(site.DomainName)
+			return changed, err
+		}
+	}
+
+	rendered, err := site.RenderConfig(envConfig)
+	if err != nil {
+		undo.run(site.DomainName)
+		return changed, err
+	}
+
+	if current, err := ioutil.ReadFile(record.Caddyfile); err != nil || !bytes.Equal(current, rendered) {
+		println(fmt.Sprintf("[%s] Caddyfile is outdated and will be rendered again", site.DomainName))
+		changed = true
+
+		if !applyDryRun {
+			// Without a previous Caddyfile there is nothing to fall back to
+			if err != nil {
+				_, err = site.UpdateConfig(envConfig)
+			} else {
+				_, err = rerenderSite(envConfig, &site, record)
+			}
+
+			if err != nil {
+				undo.run(site.DomainName)
+				return changed, err
+			}
+		}
+	}
+
+	removeReplacedPhpPool(previous, site)
+
+	if !site.IsEnabled(envConfig) {
+		println(fmt.Sprintf("[%s] Website is not enabled and will be enabled", site.DomainName))
+		changed = true
+
+		if !applyDryRun {
+			if err = enableSite(envConfig, &site); err != nil {
+				return changed, err
+			}
+		}
+	}
+
+	updated := site.Record()
+	updated.Database = record.Database
+
+	if database != nil && record.Database == nil {
+		println(fmt.Sprintf("[%s] Database is missing and will be created", site.DomainName))
+		changed = true
+
+		if !applyDryRun {
+			databaseRecord, source, err := createSiteDatabase(site, *database)
+			if err != nil {
+				return changed, err
+			}
+			source.Close()
+
+			updated.Database = databaseRecord
+		}
+	} else if database != nil && len(database.Name) > 0 && database.Name != record.Database.Name {
+		println(fmt.Sprintf("Warning: [%s] existing database %s is not renamed to %s", site.DomainName, record.Database.Name, database.Name))
+	}
+
+	if !applyDryRun && (changed || !reflect.DeepEqual(updated, record)) {
+		registerSite(envConfig, updated)
+	}
+
+	return changed, nil
+}
+
+// pruneSite disables the site and removes its Caddyfile, php-fpm pool and systemd unit. Files and database are left in place.
+func pruneSite(envConfig utils.EnvironmentConfig, record structs.SiteRecord) error {
+	println(fmt.Sprintf("[%s] Website is not declared and will be removed", record.DomainName))
+	if applyDryRun {
+		return nil
+	}
+
+	site := structs.SiteConfigFromRecord(record)
+
+	if ok, err := site.DisableSite(envConfig); !ok && !os.IsNotExist(err) {
+		return err
+	}
+
+	if ok, err := site.RemoveConfig(envConfig); !ok && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := removeSiteService(&site); err != nil {
+		return err
+	}
+
+	if removed, err := site.RemovePhpPool(phpPoolSettings()); err != nil {
+		return err
+	} else if removed {
+		if _, err = structs.ReloadPhpFpm(phpPoolSettings()); err != nil {
+			return err
+		}
+	}
+
+	if err := unregisterSite(envConfig, record.DomainName); err != nil {
+		return err
+	}
+
+	println(fmt.Sprintf("[%s] Removed Caddyfile and symlink, files in %s were left in place", record.DomainName, record.FilesRoot))
+
+	if record.Database != nil {
+		println(fmt.Sprintf("[%s] Database %s was left in place", record.DomainName, record.Database.Name))
+	}
+
+	return nil
+}
+
+// pruneUnregistered disables and removes a Caddyfile in sites-all which is not in the registry.
+func pruneUnregistered(envConfig utils.EnvironmentConfig, domain string) error {
+	println(fmt.Sprintf("[%s] Unregistered Caddyfile is not declared and will be removed", domain))
+	if applyDryRun {
+		return nil
+	}
+
+	site := structs.SiteConfig{DomainName: domain}
+
+	if ok, err := site.DisableSite(envConfig); !ok && !os.IsNotExist(err) {
+		return err
+	}
+
+	if ok, err := site.RemoveConfig(envConfig); !ok && !os.IsNotExist(err) {
+		return err
+	}
+
+	println(fmt.Sprintf("[%s] Removed Caddyfile and symlink", domain))
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", "", "Path of the sites manifest (YAML)")
+	applyCmd.Flags().BoolVar(&applyPrune, "prune", false, "Remove websites which are not declared in the manifest, registered or not")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Only show what would be changed")
+
+	applyCmd.Flags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	applyCmd.Flags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	applyCmd.Flags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+
+	_ = applyCmd.MarkFlagRequired("file")
+}
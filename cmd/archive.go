@@ -0,0 +1,168 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var archiveOutput string
+
+// archiveCmd represents the archive command
+var archiveCmd = &cobra.Command{
+	Use:   "archive <domain name>",
+	Short: "Pack a website into a single archive",
+	Long: `Pack a website into a single tar.gz archive, containing its file structure, Caddyfile, database dump and a manifest.
+The archive can be unpacked on another server with the restore command.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		domain := domainArg(args[0])
+		record := lookupSite(envConfig, domain)
+		manifest := structs.NewSiteArchiveManifest(record)
+
+		if len(archiveOutput) == 0 {
+			archiveOutput = fmt.Sprintf("%s-%s.tar.gz", domain, time.Now().Format("20060102-150405"))
+		}
+
+		workDir, err := ioutil.TempDir("", "cdm-archive-")
+		if err != nil {
+			println(fmt.Sprintf("Could not create a working directory: %s", err.Error()))
+			os.Exit(ExitGeneric)
+		}
+		defer func(workDir string) {
+			_ = os.RemoveAll(workDir)
+		}(workDir)
+
+		// os.Exit skips deferred calls
+		exit := func(code int) {
+			_ = os.RemoveAll(workDir)
+			os.Exit(code)
+		}
+
+		// Database dump has to be known in size before being added to the archive, so it goes to a file first
+		var dumpPath string
+		if record.Database != nil {
+			source := connectSiteDatabase(record)
+			defer source.Close()
+
+			if ok := source.UseDatabase(record.Database.Name); !ok {
+				println(fmt.Sprintf("Database %s does not exist", record.Database.Name))
+				source.Close()
+				exit(ExitDatabaseConnect)
+			}
+
+			manifest.DatabaseDump = "database." + strings.ToLower(string(record.Database.Type)) + ".gz"
+			dumpPath = path.Join(workDir, manifest.DatabaseDump)
+
+			if ok, err := dumpToFile(dumpPath, source.Dump); !ok {
+				println(fmt.Sprintf("There was an error while dumping the database: %s", err.Error()))
+				source.Close()
+				exit(ExitDatabaseConnect)
+			}
+
+			println(fmt.Sprintf("[%s] Dumped database %s", domain, record.Database.Name))
+		}
+
+		if err = writeSiteArchive(archiveOutput, workDir, manifest, dumpPath); err != nil {
+			_ = os.Remove(archiveOutput)
+			println(fmt.Sprintf("There was an error while creating the archive: %s", err.Error()))
+			exit(ExitFileStructure)
+		}
+
+		println(fmt.Sprintf("[%s] Archived website to %s", domain, archiveOutput))
+	},
+}
+
+func writeSiteArchive(output, workDir string, manifest structs.SiteArchiveManifest, dumpPath string) error {
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	compressed := gzip.NewWriter(file)
+	tw := tar.NewWriter(compressed)
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	manifestPath := path.Join(workDir, structs.ArchiveManifestName)
+	if err = ioutil.WriteFile(manifestPath, content, 0600); err != nil {
+		return err
+	}
+
+	// Manifest goes first, so it can be read without unpacking everything
+	if err = utils.TarFile(tw, structs.ArchiveManifestName, manifestPath); err != nil {
+		return err
+	}
+
+	if err = utils.TarFile(tw, structs.ArchiveCaddyfileName, manifest.Site.Caddyfile); err != nil {
+		return err
+	}
+
+	if len(dumpPath) > 0 {
+		if err = utils.TarFile(tw, manifest.DatabaseDump, dumpPath); err != nil {
+			return err
+		}
+	}
+
+	// Old credentials are useless after restore, new ones are written there
+	err = utils.TarDirectory(tw, manifest.Site.FilesRoot, structs.ArchiveFilesDir, func(relative string) bool {
+		return relative == "database_info.txt"
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	return compressed.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+
+	archiveCmd.Flags().StringVarP(&archiveOutput, "output", "f", "", "Path of the archive to create. Optional, by default <domain>-<date>.tar.gz in the current directory.")
+
+	archiveCmd.Flags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	archiveCmd.Flags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	archiveCmd.Flags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+}
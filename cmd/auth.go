@@ -0,0 +1,34 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage HTTP basic auth protection of existing websites",
+	Long: `Protect websites with HTTP basic authentication, i.e. staging ones. Passwords are hashed with bcrypt and stored in the sites registry,
+the basicauth block is added to the website's Caddyfile (at the beginning of its first site block, unless the template uses .BasicAuth itself).
+The whole Caddy configuration is validated and Caddy is reloaded. If validation fails, the previous Caddyfile is kept.`,
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// authListCmd represents the auth list command
+var authListCmd = &cobra.Command{
+	Use:   "list <domain name>",
+	Short: "List users allowed into a website",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		record := lookupSite(envConfig, domainArg(args[0]))
+
+		if len(record.BasicAuth) == 0 {
+			println(fmt.Sprintf("[%s] Website is not protected with basic auth", record.DomainName))
+			return
+		}
+
+		for _, user := range record.BasicAuth {
+			fmt.Println(user.User)
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authListCmd)
+}
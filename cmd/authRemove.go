@@ -0,0 +1,63 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+)
+
+// authRemoveCmd represents the auth remove command
+var authRemoveCmd = &cobra.Command{
+	Use:   "remove <domain name> <user>...",
+	Short: "Remove users allowed into a website",
+	Long:  `Remove users allowed into the website. When the last one is removed, the website is not protected anymore.`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeSite(args[0], func(registry *structs.SiteRegistry, record structs.SiteRecord, site *structs.SiteConfig) error {
+			allowed := map[string]bool{}
+			for _, existing := range site.BasicAuth {
+				allowed[existing.User] = true
+			}
+
+			removed := map[string]bool{}
+			for _, user := range args[1:] {
+				if !allowed[user] {
+					return fmt.Errorf("%s is not allowed into %s", user, record.DomainName)
+				}
+
+				removed[user] = true
+			}
+
+			var users []structs.BasicAuthUser
+			for _, existing := range site.BasicAuth {
+				if !removed[existing.User] {
+					users = append(users, existing)
+				}
+			}
+
+			site.BasicAuth = users
+
+			return nil
+		})
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authRemoveCmd)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+)
+
+var authPassword string
+
+// authSetCmd represents the auth set command
+var authSetCmd = &cobra.Command{
+	Use:   "set <domain name> <user>",
+	Short: "Allow a user into a website, protecting it with basic auth",
+	Long: `Allow the user into the website with given password, or a generated one printed after the Caddyfile is updated.
+The password of an already allowed user is replaced.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var generated string
+
+		changeSite(args[0], func(registry *structs.SiteRegistry, record structs.SiteRecord, site *structs.SiteConfig) error {
+			user, password, err := basicAuthUser(args[1], authPassword)
+			if err != nil {
+				return err
+			}
+
+			if len(authPassword) == 0 {
+				generated = password
+			}
+
+			var users []structs.BasicAuthUser
+			for _, existing := range site.BasicAuth {
+				if existing.User != user.User {
+					users = append(users, existing)
+				}
+			}
+
+			site.BasicAuth = append(users, user)
+
+			return nil
+		})
+
+		if len(generated) > 0 {
+			println(fmt.Sprintf("Basic auth user: %s, password: %s", args[1], generated))
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authSetCmd)
+
+	authSetCmd.Flags().StringVar(&authPassword, "password", "", "Password of the user. Optional, randomly generated by default.")
+}
@@ -0,0 +1,233 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	clonePort              string
+	cloneBasicAuth         string
+	cloneBasicAuthPassword string
+)
+
+// cloneSiteCmd represents the cloneSite command
+var cloneSiteCmd = &cobra.Command{
+	Use:   "cloneSite <source domain> <clone domain>",
+	Short: "Copy a website to another domain, i.e. a staging subdomain",
+	Long: `Copy a website to another domain: its files root is copied (without files roots of other websites nested in it),
+a Caddyfile of the same type is created and enabled, and the clone gets its own php-fpm pool, systemd unit and system user
+if the source has them. If the source has a database, a fresh database and user are created, the data is copied and
+credentials in database_info.txt, .env and wp-config.php files of the clone are rewritten.
+Application clones run on a port of their own. With --basic-auth, the clone is protected with HTTP basic authentication
+and a generated (or given) password. If any step fails, all previous ones are undone.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		sourceDomain := domainArg(args[0])
+
+		cloneDomain, displayName, err := utils.NormalizeDomain(args[1])
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitUsage)
+		}
+
+		registry := loadRegistry(envConfig)
+
+		record, err := registry.Get(sourceDomain)
+		if err != nil {
+			println(fmt.Sprintf("Site %s is not registered", sourceDomain))
+			os.Exit(ExitGeneric)
+		}
+
+		if owner, ok := registry.Owner(cloneDomain); ok {
+			println(fmt.Sprintf("%s is already served by %s", cloneDomain, owner))
+			os.Exit(ExitUsage)
+		}
+
+		site := structs.SiteConfigFromRecord(record)
+
+		clone, err := site.Cloned(envConfig, cloneDomain, displayName)
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitFileStructure)
+		}
+
+		// Cloned drops the custom certificate, as it does not cover the clone
+		if site.Tls.Mode == structs.TlsCustom {
+			println(fmt.Sprintf("Warning: [%s] custom certificate of %s is not used by the clone, it gets the certificate its template configures. Set another one with updateSite --tls", cloneDomain, sourceDomain))
+		}
+
+		if clone.Type.Base() == utils.ProgramTypeApp {
+			if len(clone.Upstreams) > 0 {
+				println(fmt.Sprintf("Warning: [%s] upstreams of %s are not cloned, the clone proxies to its own port", cloneDomain, sourceDomain))
+				clone.Upstreams, clone.LbPolicy, clone.HealthPath, clone.HealthEvery = nil, "", "", ""
+			}
+
+			if clone.Port, err = sitePort(envConfig, clonePort); err != nil {
+				println(err.Error())
+				os.Exit(exitCode(err))
+			}
+		}
+
+		var generatedPassword string
+		if len(cloneBasicAuth) > 0 {
+			user, password, err := basicAuthUser(cloneBasicAuth, cloneBasicAuthPassword)
+			if err != nil {
+				println(err.Error())
+				os.Exit(ExitUsage)
+			}
+
+			if len(cloneBasicAuthPassword) == 0 {
+				generatedPassword = password
+			}
+
+			clone.BasicAuth = []structs.BasicAuthUser{user}
+		}
+
+		// Files roots of other sites nested in the source one
+		var nested []string
+		for _, domain := range registry.SitesInside(site.FilesRoot()) {
+			nested = append(nested, registry.Sites[domain].FilesRoot)
+		}
+
+		var undo rollback
+
+		fail := func(err error) {
+			println(err.Error())
+			undo.run(cloneDomain)
+			os.Exit(exitCode(err))
+		}
+
+		if ok, err := site.CopyFileStructure(envConfig, clone, nested); !ok {
+			fail(withExitCode(ExitFileStructure, fmt.Errorf("could not copy %s to %s: %w", site.FilesRoot(), clone.FilesRoot(), err)))
+		}
+
+		undo.add(func() error {
+			return os.RemoveAll(clone.FilesRoot())
+		})
+
+		println(fmt.Sprintf("[%s] Copied files from %s to %s", cloneDomain, site.FilesRoot(), clone.FilesRoot()))
+
+		if len(site.SystemUser) > 0 {
+			if err = createSiteUser(envConfig, &clone, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		if len(site.PhpSocket) > 0 {
+			if err = createSitePhpPool(&clone, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		if len(site.Service) > 0 {
+			if err = createSiteService(envConfig, &clone, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		if err = createSiteCaddyfile(envConfig, &clone); err != nil {
+			fail(err)
+		}
+
+		undo.add(func() error {
+			_, err := clone.RemoveConfig(envConfig)
+			return err
+		})
+
+		if err = enableSite(envConfig, &clone); err != nil {
+			fail(err)
+		}
+
+		undo.add(func() error {
+			_, err := clone.DisableSite(envConfig)
+			return err
+		})
+
+		if ok, err := clone.ValidateCaddy(envConfig); !ok {
+			fail(withExitCode(ExitCaddyInvalid, err))
+		}
+
+		cloneRecord := clone.Record()
+
+		if record.Database != nil {
+			database, err := copySiteDatabase(clone, *record.Database, utils.RandomPassword(16))
+			if err != nil {
+				fail(err)
+			}
+
+			undo.add(func() error {
+				return dropSiteDatabase(*database)
+			})
+
+			writeDatabaseInfo(clone, *database)
+
+			changed, err := clone.RewriteDatabaseCredentials(*record.Database, *database)
+			if err != nil {
+				fail(withExitCode(ExitFileStructure, fmt.Errorf("could not rewrite database credentials: %w", err)))
+			}
+
+			for _, file := range changed {
+				println(fmt.Sprintf("[%s] Rewrote database credentials in %s", cloneDomain, file))
+			}
+
+			cloneRecord.Database = database
+		}
+
+		registerSite(envConfig, cloneRecord)
+
+		undo.add(func() error {
+			return unregisterSite(envConfig, cloneDomain)
+		})
+
+		if ok, err := clone.ReloadCaddy(envConfig); !ok {
+			fail(withExitCode(ExitCaddyReload, err))
+		}
+
+		if len(generatedPassword) > 0 {
+			println(fmt.Sprintf("[%s] Protected with basic auth, user: %s, password: %s", cloneDomain, cloneBasicAuth, generatedPassword))
+		} else if len(cloneBasicAuth) > 0 {
+			println(fmt.Sprintf("[%s] Protected with basic auth, user: %s", cloneDomain, cloneBasicAuth))
+		}
+
+		println(fmt.Sprintf("[%s] Website %s cloned to %s", cloneDomain, sourceDomain, cloneDomain))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cloneSiteCmd)
+
+	cloneSiteCmd.Flags().StringVarP(&clonePort, "port", "p", PortAuto, "Port of the cloned application, or 'auto' to pick a free one from the configured range")
+	cloneSiteCmd.Flags().StringVar(&cloneBasicAuth, "basic-auth", "", "Protect the clone with basic auth, allowing given user in")
+	cloneSiteCmd.Flags().StringVar(&cloneBasicAuthPassword, "basic-auth-password", "", "Password of the basic auth user. Optional, randomly generated by default.")
+
+	cloneSiteCmd.Flags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	cloneSiteCmd.Flags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	cloneSiteCmd.Flags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+}
@@ -18,22 +18,23 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/kovansky/caddyDomainManager/cmd/databases"
 	"github.com/kovansky/caddyDomainManager/cmd/structs"
 	"github.com/kovansky/caddyDomainManager/cmd/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/term"
 	"os"
-	"strconv"
 	"strings"
-	"syscall"
 )
 
 var (
-	port            int
+	portValue       string
 	forceBaseDomain bool
 
+	upstreams      []string
+	lbPolicy       string
+	healthPath     string
+	healthInterval string
+
 	dbTypeString string
 	dbType       utils.DatabaseType
 
@@ -46,232 +47,210 @@ var (
 	dbUserPassword string
 	dbUserHost     string
 	dbDatabaseName string
+
+	batchFile        string
+	batchConcurrency int
+
+	outputFormat string
+
+	aliases []string
+	wwwMode string
+
+	templateVars []string
+
+	systemUser bool
+
+	enableService bool
+	startService  bool
+
+	basicAuthName     string
+	basicAuthPassword string
+
+	tlsMode  string
+	tlsCert  string
+	tlsKey   string
+	tlsEmail string
+	tlsCa    string
+	tlsDns   string
+
+	presetName string
 )
 
 // createSiteCmd represents the createSite command
 var createSiteCmd = &cobra.Command{
 	Use:   "createSite <domain name> [website type]",
 	Short: "Create a new website",
-	Long:  `Create a new website, including its home directory, database and user in given server (mysql, mongo) and Caddy config.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Create a new website, including its home directory, database and user in given server (mysql, mongo) and Caddy config.
+With --from-file, websites listed in a CSV file (domain, type, port, db-type columns) are created instead, and Caddy is reloaded once.
+Applications without a port in the file get a free one from the configured range.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(batchFile) > 0 {
+			return cobra.NoArgs(cmd, args)
+		}
+
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		domain, displayName := "", ""
+		if len(args) > 0 {
+			domain = strings.ToLower(args[0])
+		}
+
+		// fail reports errors which happen before anything is created
+		fail := func(code int, err error) {
+			if outputFormat == OutputJson {
+				printJson(newSiteResult(domain, structs.SiteRecord{}, withExitCode(code, err)))
+			} else {
+				println(err.Error())
+			}
+
+			os.Exit(code)
+		}
+
+		if outputFormat != OutputText && outputFormat != OutputJson {
+			fail(ExitUsage, fmt.Errorf("%s is not correct output format. Please, use 'text' or 'json'", outputFormat))
+		}
+
 		// Read required environment variables
 		envConfig := utils.EnvironmentConfig{}
 
 		if ok, missing := envConfig.ReadEnvironments(); !ok {
 			// One of the required environment variables is missing
-			println("You are missing a required environment variable ", missing)
-			return
+			fail(ExitUsage, fmt.Errorf("you are missing a required environment variable %s", missing))
 		}
 
-		siteConfig := structs.SiteConfig{
-			Type:       utils.ProgramTypePhp,
-			DomainName: strings.ToLower(args[0]),
+		if portValue != PortAuto {
+			if _, err := sitePort(envConfig, portValue); err != nil {
+				fail(ExitUsage, err)
+			}
 		}
 
-		if len(args) > 1 {
-			siteConfig.Type = utils.GetProgramType(args[1])
+		if len(batchFile) > 0 {
+			createSitesFromFile(envConfig, batchFile)
+			return
 		}
 
-		if siteConfig.Type == utils.ProgramTypeApp {
-			siteConfig.Port = port
+		// Domain is used in paths, Caddyfile and SQL, so it has to be a proper host name
+		var err error
+		if domain, displayName, err = utils.NormalizeDomain(domain); err != nil {
+			fail(ExitUsage, err)
 		}
 
-		if forceBaseDomain {
-			siteConfig.ForceBase = true
+		siteConfig := structs.SiteConfig{
+			Type:        utils.ProgramTypePhp,
+			DomainName:  domain,
+			DisplayName: displayName,
 		}
 
-		if ok, err := siteConfig.CreateFileStructure(envConfig); !ok {
-			if os.IsNotExist(err) {
-				println(fmt.Sprintf("Warning: template directory for %s type does not exist; omitting file structure copy.", strings.ToLower(string(siteConfig.Type))))
-			} else if err.Error() == "domain directory not empty" {
-				println(fmt.Sprintf("Warning: directory structure for %s already exists and is not empty; omitting file structure copy.", siteConfig.DomainName))
-			} else {
-				panic(err)
+		if len(args) > 1 {
+			if siteConfig.Type, err = utils.GetProgramType(args[1]); err != nil {
+				fail(ExitUsage, err)
 			}
 		}
 
-		println(fmt.Sprintf("[%s] Created file structure in %s using %s template", siteConfig.DomainName, siteConfig.FilesRoot(), strings.ToLower(string(siteConfig.Type))))
-
-		if ok, err := siteConfig.CreateConfig(envConfig); !ok {
-			if os.IsExist(err) {
-				println(fmt.Sprintf("Config file for domain %s already exists", siteConfig.DomainName))
-				os.Exit(1)
-			} else if os.IsNotExist(err) {
-				println(fmt.Sprintf("Template file for type %s do not exist", strings.ToLower(string(siteConfig.Type))))
-				os.Exit(1)
-			} else {
-				panic(err)
-			}
+		if siteConfig.Vars, err = parseTemplateVars(templateVars); err != nil {
+			fail(ExitUsage, err)
 		}
 
-		println(fmt.Sprintf("[%s] Created Caddyfile config in %s using %s template", siteConfig.DomainName, siteConfig.Caddyfile(), strings.ToLower(string(siteConfig.Type))))
+		if siteConfig.Type.Base() == utils.ProgramTypeApp {
+			// Port declared by the type is used, unless --port is given
+			if len(upstreams) == 0 && (cmd.Flags().Changed("port") || siteConfig.Type.Definition().Port == 0) {
+				if siteConfig.Port, err = sitePort(envConfig, portValue); err != nil {
+					fail(exitCode(err), err)
+				}
+			}
 
-		if ok, err := siteConfig.EnableSite(envConfig); !ok {
-			if os.IsNotExist(err) {
-				println(fmt.Sprintf("Caddyfile for domain %s do not exist", strings.ToLower(siteConfig.DomainName)))
-				os.Exit(1)
-			} else {
-				panic(err)
+			if err = normalizeUpstreams(&siteConfig, upstreams, lbPolicy, healthPath, healthInterval); err != nil {
+				fail(ExitUsage, err)
 			}
 		}
 
-		println(fmt.Sprintf("[%s] Created symlink for Caddyfile in sites-enabled directory", siteConfig.DomainName))
-
-		// Reload caddy
-		siteConfig.ReloadCaddy(envConfig)
-
-		// Database configuration
-		dbType = utils.GetDatabaseType(dbTypeString)
-		var keysPrefix string
-
-		switch dbType {
-		case utils.DatabaseMongo:
-			keysPrefix = "mongo."
-			break
-		case utils.DatabaseMysql:
-			keysPrefix = "mysql."
-			break
+		if err = siteConfig.ApplyTypeDefaults(); err != nil {
+			fail(ExitUsage, err)
 		}
 
-		if dbType == utils.DatabaseNone {
-			if len(dbTypeString) > 0 {
-				println(fmt.Sprintf("%s is not correct type of database. Please, use 'mysql' or 'mongo'", dbTypeString))
-			}
-		} else {
-			if len(dbAdminUser) == 0 {
-				conf := viper.GetString(keysPrefix + "username")
-				if len(conf) > 0 {
-					dbAdminUser = conf
-				} else {
-					println("You are missing a database admin username (--db-admin, -U).")
-					return
-				}
-			}
-
-			if len(dbAdminPassword) == 0 {
-				conf := viper.GetString(keysPrefix + "password")
-				if len(conf) > 0 {
-					dbAdminPassword = conf
-				} else {
-					println(fmt.Sprintf("Please, provide database password for user %s", dbAdminUser))
-
-					bytePassword, err := term.ReadPassword(int(syscall.Stdin))
-					if err != nil {
-						panic(err)
-						return
-					}
+		if forceBaseDomain {
+			siteConfig.ForceBase = true
+		}
 
-					dbAdminPassword = string(bytePassword)
-				}
-			}
+		if siteConfig.Aliases, err = normalizeAliases(domain, aliases); err != nil {
+			fail(ExitUsage, err)
+		}
 
-			if len(dbHost) == 0 || dbHost == "127.0.0.1" {
-				conf := viper.GetString(keysPrefix + "host")
-				if len(conf) > 0 && dbHost != "127.0.0.1" {
-					dbHost = conf
-				} else {
-					var port int
-
-					switch dbType {
-					case utils.DatabaseMongo:
-						port = 27017
-						break
-					case utils.DatabaseMysql:
-						port = 3306
-						break
-					}
-
-					dbHost = fmt.Sprintf("127.0.0.1:%d", port)
-				}
-			}
+		if !structs.ValidWwwMode(wwwMode) {
+			fail(ExitUsage, fmt.Errorf("%s is not correct www mode. Please, use 'redirect', 'alias' or 'none'", wwwMode))
+		}
+		siteConfig.Www = wwwMode
 
-			if len(dbUserName) == 0 {
-				/*
-				   Build user name from domain. I.e. when domain is example.com - username is example.
-				   When domain is test.example.com - username is test_example
-				*/
-				domainParts := siteConfig.DomainStructure(true)
-				dbUserName = strings.Split(strings.Join(domainParts, "_"), ".")[0] // Get rid of TLD
-			}
+		if siteConfig.Tls, err = siteTls(tlsMode, tlsCert, tlsKey, tlsEmail, tlsCa, tlsDns); err != nil {
+			fail(ExitUsage, err)
+		}
 
-			if len(dbDatabaseName) == 0 {
-				dbDatabaseName = dbUserName
-			}
+		if siteConfig.Preset, err = sitePreset(presetName); err != nil {
+			fail(ExitUsage, err)
+		}
 
-			if len(dbUserPassword) == 0 {
-				dbUserPassword = utils.RandomPassword(16)
+		// Generated password is shown once the site is created
+		var generatedPassword string
+		if len(basicAuthName) > 0 {
+			user, password, err := basicAuthUser(basicAuthName, basicAuthPassword)
+			if err != nil {
+				fail(ExitUsage, err)
 			}
 
-			if len(dbUserHost) == 0 {
-				switch dbType {
-				case utils.DatabaseMysql:
-					dbUserHost = "localhost"
-					break
-				case utils.DatabaseMongo:
-					dbUserHost = "127.0.0.1"
-					break
-				}
+			if len(basicAuthPassword) == 0 {
+				generatedPassword = password
 			}
 
-			splitted := strings.Split(dbHost, ":")
-
-			if len(splitted) != 2 {
-				println(fmt.Sprintf("The host (%s) is in incorrect format - it should be host:port", dbHost))
-				return
-			}
+			siteConfig.BasicAuth = []structs.BasicAuthUser{user}
+		}
 
-			dbHost = splitted[0]
-			port, err := strconv.Atoi(splitted[1])
+		// Database configuration
+		var database *databaseRequest
+		dbType = utils.GetDatabaseType(dbTypeString)
 
-			if err != nil {
-				panic(err)
+		if dbType == utils.DatabaseNone {
+			if len(dbTypeString) > 0 {
+				fail(ExitUsage, fmt.Errorf("%s is not correct type of database. Please, use 'mysql' or 'mongo'", dbTypeString))
 			}
-
-			// Try to create database
-			var source databases.DatabaseSource
-			switch dbType {
-			case utils.DatabaseMongo:
-				source = &databases.MongoSource{
-					User:     dbAdminUser,
-					Password: dbAdminPassword,
-					Host:     dbHost,
-					Port:     port,
-					AuthDb:   dbAuthDatabase,
-				}
-				break
-			case utils.DatabaseMysql:
-				source = &databases.MysqlSource{
-					User:     dbAdminUser,
-					Password: dbAdminPassword,
-					Host:     dbHost,
-					Port:     port,
-				}
-				break
+		} else {
+			database = &databaseRequest{
+				Type:     dbType,
+				Host:     dbHost,
+				Name:     dbDatabaseName,
+				User:     dbUserName,
+				Password: dbUserPassword,
+				UserHost: dbUserHost,
 			}
+		}
 
-			if ok := source.Connect(); !ok {
-				println("There was an error while connecting to the database server")
-				return
-			}
-			defer source.Close()
+		record, err := provisionSite(envConfig, &siteConfig, database)
+		reloaded := false
 
-			if ok := source.CreateDatabase(dbDatabaseName); !ok {
-				println("There was an error while creating the database")
-				return
+		// Reload caddy, if the site got enabled - even when its database failed
+		if len(record.DomainName) > 0 {
+			var reloadErr error
+			if reloaded, reloadErr = siteConfig.ReloadCaddy(envConfig); err == nil {
+				err = withExitCode(ExitCaddyReload, reloadErr)
 			}
+		}
 
-			println(fmt.Sprintf("[%s] Created database %s in %s server %s:%d", siteConfig.DomainName, dbDatabaseName, strings.ToLower(string(dbType)), dbHost, port))
+		if outputFormat == OutputJson {
+			result := newSiteResult(domain, record, err)
+			result.Reloaded = reloaded
 
-			if ok := source.CreateUser(dbUserName, dbUserHost, dbUserPassword); !ok {
-				println("There was an error while creating the database user")
-				return
+			if len(generatedPassword) > 0 && len(record.DomainName) > 0 {
+				result.BasicAuth = &siteResultBasicAuth{User: basicAuthName, Password: generatedPassword}
 			}
 
-			siteConfig.WriteDatabaseInfo(dbHost, port, dbDatabaseName, dbUserName, dbUserPassword, dbUserHost)
-
-			println(fmt.Sprintf("[%s] Created user %s (with connection limited to %s) and granted privileges on %s in %s server %s:%d. All required information were stored in database_info.txt file in website's root directory", siteConfig.DomainName, dbUserName, dbUserHost, dbDatabaseName, strings.ToLower(string(dbType)), dbHost, port))
+			printJson(result)
+		} else if err != nil {
+			println(err.Error())
+		} else if len(generatedPassword) > 0 {
+			println(fmt.Sprintf("[%s] Protected with basic auth, user: %s, password: %s", domain, basicAuthName, generatedPassword))
 		}
+
+		os.Exit(exitCode(err))
 	},
 }
 
@@ -283,7 +262,11 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	// createSiteCmd.PersistentFlags().String("foo", "", "A help for foo")
-	createSiteCmd.Flags().IntVarP(&port, "port", "p", 8080, "A port of application behind the proxy")
+	createSiteCmd.Flags().StringVarP(&portValue, "port", "p", "8080", "A port of application behind the proxy, or 'auto' to pick a free one from the configured range")
+	createSiteCmd.Flags().StringArrayVar(&upstreams, "upstream", nil, "Address (host:port) of an application instance behind the proxy, instead of --port. Can be repeated.")
+	createSiteCmd.Flags().StringVar(&lbPolicy, "lb-policy", "", "Load balancing policy between upstreams, i.e. round_robin or least_conn")
+	createSiteCmd.Flags().StringVar(&healthPath, "health-path", "", "Path of active health checks of upstreams, i.e. /health")
+	createSiteCmd.Flags().StringVar(&healthInterval, "health-interval", "", "Interval of active health checks, i.e. 10s")
 	createSiteCmd.Flags().BoolVarP(&forceBaseDomain, "basedomain", "b", false, "Force to treat the domain as high-level, even if contains subdomains")
 
 	createSiteCmd.Flags().StringVarP(&dbTypeString, "db-type", "t", "", "Type of database to use (MySQL or Mongo). If this flag is present an user in corresponding database will be created. Requires providing all other database-related flags.")
@@ -298,7 +281,29 @@ func init() {
 	createSiteCmd.Flags().StringVarP(&dbUserHost, "host", "o", "", "Host to which the database user to create should be limited while connecting. Optional, localhost by default.")
 	createSiteCmd.Flags().StringVarP(&dbDatabaseName, "database", "D", "", "Name of the database to create. Optional, default equal to the username.")
 
+	createSiteCmd.Flags().StringVar(&batchFile, "from-file", "", "CSV file with websites to create (domain, type, port, db-type columns)")
+	createSiteCmd.Flags().IntVar(&batchConcurrency, "concurrency", 4, "Number of websites from --from-file created at the same time")
+	createSiteCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable as name=value, available as $NAME and {{.Vars.name}}. Can be repeated.")
+	createSiteCmd.Flags().StringArrayVar(&aliases, "alias", nil, "Additional domain served by the website. Can be repeated.")
+	createSiteCmd.Flags().StringVar(&wwwMode, "www", structs.WwwNone, "Handling of the www subdomain: 'redirect' (to the domain), 'alias' (served as the domain) or 'none'")
+	createSiteCmd.Flags().BoolVar(&systemUser, "system-user", false, "Create a system user and group owning the website's files, readable by the web server user from config")
+	createSiteCmd.Flags().BoolVar(&enableService, "enable-service", false, "Enable the systemd unit of an application website, so it starts on boot")
+	createSiteCmd.Flags().BoolVar(&startService, "start-service", false, "Start the systemd unit of an application website")
+	createSiteCmd.Flags().StringVar(&basicAuthName, "basic-auth", "", "Protect the website with basic auth, allowing given user in")
+	createSiteCmd.Flags().StringVar(&basicAuthPassword, "basic-auth-password", "", "Password of the basic auth user. Optional, randomly generated by default.")
+	createSiteCmd.Flags().StringVar(&tlsMode, "tls", "", "Certificate of the website: 'internal' (Caddy's internal CA), 'acme' or 'custom'. Left to the template by default.")
+	createSiteCmd.Flags().StringVar(&tlsCert, "cert", "", "Certificate file (absolute path), for --tls custom")
+	createSiteCmd.Flags().StringVar(&tlsKey, "key", "", "Key file (absolute path), for --tls custom")
+	createSiteCmd.Flags().StringVar(&tlsEmail, "acme-email", "", "ACME account email, for --tls acme. Optional, tls.email from config by default.")
+	createSiteCmd.Flags().StringVar(&tlsCa, "acme-ca", "", "ACME directory URL, for --tls acme. Optional, tls.ca from config or Caddy's default.")
+	createSiteCmd.Flags().StringVar(&tlsDns, "acme-dns", "", "DNS provider solving the DNS challenge, i.e. 'cloudflare {env.CF_API_TOKEN}', for --tls acme. Requires Caddy built with the provider's module.")
+	createSiteCmd.Flags().StringVar(&presetName, "preset", "", "Hardening preset adding security headers and blocking dotfiles, i.e. 'basic' or 'strict'. Presets can be declared in config.")
+	createSiteCmd.Flags().StringVar(&outputFormat, "output", OutputText, "Output format, 'text' or 'json'. In json mode a result object is printed to stdout, while progress messages still go to stderr.")
+
 	viper.BindPFlag("mongo.authDatabase", createSiteCmd.Flag("db-auth-db"))
+	viper.BindPFlag("systemUser.enabled", createSiteCmd.Flag("system-user"))
+	viper.BindPFlag("systemd.enable", createSiteCmd.Flag("enable-service"))
+	viper.BindPFlag("systemd.start", createSiteCmd.Flag("start-service"))
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,160 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"os"
+	"sync"
+)
+
+// createSitesFromFile creates every site listed in the CSV file, running at most batchConcurrency
+// of them at once, and reloads Caddy once at the end. Sites of the same registrable domain share directories
+// (and the parent's files root in the nested layout), so they are created one after another.
+func createSitesFromFile(envConfig utils.EnvironmentConfig, file string) {
+	entries, err := structs.ReadSitesCsv(file)
+	if err != nil {
+		println(fmt.Sprintf("Could not read sites from %s: %s", file, err.Error()))
+		os.Exit(ExitUsage)
+	}
+
+	// Credentials may have to be asked for, which cannot happen from concurrent workers
+	for _, entry := range entries {
+		if entry.Database == nil {
+			continue
+		}
+
+		if err = resolveDatabaseAdmin(utils.GetDatabaseType(entry.Database.Type)); err != nil {
+			println(err.Error())
+			os.Exit(ExitDatabaseConfig)
+		}
+	}
+
+	concurrency := batchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Locks are created upfront, so workers only read the map
+	locks := map[string]*sync.Mutex{}
+	for _, entry := range entries {
+		registrable := registrableDomain(entry.SiteConfig().DomainName)
+		if _, ok := locks[registrable]; !ok {
+			locks[registrable] = &sync.Mutex{}
+		}
+	}
+
+	results := make([]siteResult, len(entries))
+	enabled := make([]bool, len(entries))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, entry := range entries {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int, entry structs.SiteManifestEntry) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			siteConfig := entry.SiteConfig()
+			if err := siteConfig.ApplyTypeDefaults(); err != nil {
+				results[i] = newSiteResult(entry.Domain, structs.SiteRecord{}, withExitCode(ExitUsage, err))
+				return
+			}
+
+			// A single --port can't serve every row, so applications without a port of their own get a free one
+			if siteConfig.Type.Base() == utils.ProgramTypeApp && siteConfig.Port == 0 && len(siteConfig.Upstreams) == 0 {
+				var err error
+				if siteConfig.Port, err = sitePort(envConfig, PortAuto); err != nil {
+					results[i] = newSiteResult(entry.Domain, structs.SiteRecord{}, err)
+					return
+				}
+			}
+
+			var database *databaseRequest
+			if entry.Database != nil {
+				database = &databaseRequest{
+					Type: utils.GetDatabaseType(entry.Database.Type),
+					Host: dbHost,
+				}
+			}
+
+			lock := locks[registrableDomain(siteConfig.DomainName)]
+			lock.Lock()
+			record, err := provisionSite(envConfig, &siteConfig, database)
+			lock.Unlock()
+
+			results[i] = newSiteResult(entry.Domain, record, err)
+			enabled[i] = len(record.DomainName) > 0
+		}(i, entry)
+	}
+
+	wg.Wait()
+
+	// Caddy is reloaded if any site got enabled - even when its database failed
+	attempted, reloaded := false, false
+	for _, siteEnabled := range enabled {
+		attempted = attempted || siteEnabled
+	}
+
+	if attempted {
+		reloaded, _ = structs.SiteConfig{}.ReloadCaddy(envConfig)
+	}
+
+	failed := 0
+	for i := range results {
+		results[i].Reloaded = reloaded
+
+		if results[i].ExitCode != ExitOk {
+			failed++
+		}
+	}
+
+	if outputFormat == OutputJson {
+		printJson(results)
+	} else {
+		println("Summary:")
+
+		for _, result := range results {
+			if result.ExitCode != ExitOk {
+				println(fmt.Sprintf("  FAILED  %s: %s", result.Domain, result.Error))
+			} else {
+				println(fmt.Sprintf("  OK      %s", result.Domain))
+			}
+		}
+
+		println(fmt.Sprintf("Created %d of %d websites", len(results)-failed, len(results)))
+	}
+
+	if failed > 0 {
+		os.Exit(ExitPartialFailure)
+	} else if attempted && !reloaded {
+		os.Exit(ExitCaddyReload)
+	}
+}
+
+// registrableDomain returns the domain registered under a public suffix, or the domain itself if it has none.
+func registrableDomain(domain string) string {
+	if registrable := utils.RegistrableDomain(domain); len(registrable) > 0 {
+		return registrable
+	}
+
+	return domain
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/databases"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// databaseAdmin holds administrator credentials for one type of database server.
+type databaseAdmin struct {
+	User     string
+	Password string
+}
+
+var (
+	databaseAdminsMutex sync.Mutex
+	databaseAdmins      = map[utils.DatabaseType]databaseAdmin{}
+)
+
+// resolveDatabaseAdmin finds administrator credentials for the type of database: flags come first, then the config file,
+// asking for the password as a last resort. Credentials are resolved once per type, as sites of a batch may use different servers.
+func resolveDatabaseAdmin(dbType utils.DatabaseType) error {
+	databaseAdminsMutex.Lock()
+	defer databaseAdminsMutex.Unlock()
+
+	if _, ok := databaseAdmins[dbType]; ok {
+		return nil
+	}
+
+	keysPrefix := strings.ToLower(string(dbType)) + "."
+	admin := databaseAdmin{User: dbAdminUser, Password: dbAdminPassword}
+
+	if len(admin.User) == 0 {
+		conf := viper.GetString(keysPrefix + "username")
+		if len(conf) > 0 {
+			admin.User = conf
+		} else {
+			return errors.New("you are missing a database admin username (--db-admin, -U)")
+		}
+	}
+
+	if len(admin.Password) == 0 {
+		conf := viper.GetString(keysPrefix + "password")
+		if len(conf) > 0 {
+			admin.Password = conf
+		} else {
+			println(fmt.Sprintf("Please, provide %s database password for user %s", strings.ToLower(string(dbType)), admin.User))
+
+			bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+			if err != nil {
+				return err
+			}
+
+			admin.Password = string(bytePassword)
+		}
+	}
+
+	databaseAdmins[dbType] = admin
+
+	return nil
+}
+
+// newDatabaseSource creates a source of given type, authenticated as the database administrator resolved for the type.
+func newDatabaseSource(dbType utils.DatabaseType, host string, port int) databases.DatabaseSource {
+	databaseAdminsMutex.Lock()
+	admin := databaseAdmins[dbType]
+	databaseAdminsMutex.Unlock()
+
+	switch dbType {
+	case utils.DatabaseMongo:
+		return &databases.MongoSource{
+			User:     admin.User,
+			Password: admin.Password,
+			Host:     host,
+			Port:     port,
+			AuthDb:   dbAuthDatabase,
+		}
+	case utils.DatabaseMysql:
+		return &databases.MysqlSource{
+			User:     admin.User,
+			Password: admin.Password,
+			Host:     host,
+			Port:     port,
+		}
+	}
+
+	return nil
+}
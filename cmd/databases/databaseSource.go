@@ -1,8 +1,16 @@
 package databases
 
+import "io"
+
 type DatabaseSource interface {
 	Connect() bool
 	CreateUser(name string, userHost string, password string) bool
 	CreateDatabase(name string) bool
+	UseDatabase(name string) bool
+	UserExists(name string, userHost string) bool
+	Dump(w io.Writer) (bool, error)
+	Restore(r io.Reader) (bool, error)
+	DropUser(name string, userHost string) bool
+	DropDatabase(name string) bool
 	Close()
 }
@@ -1,6 +1,7 @@
 package databases
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
@@ -8,6 +9,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"io"
 	"time"
 )
 
@@ -77,10 +79,10 @@ func (source MongoSource) CreateUser(name string, userHost string, password stri
 	defer cancel()
 
 	result := source.database.RunCommand(ctx, bson.D{
-		{"createUser", name},
-		{"pwd", password},
-		{"roles", []bson.M{{"role": "readWrite", "db": source.database.Name()}}},
-		{"authenticationRestrictions", []bson.M{{"clientSource": bson.A{userHost}}}},
+		{Key: "createUser", Value: name},
+		{Key: "pwd", Value: password},
+		{Key: "roles", Value: []bson.M{{"role": "readWrite", "db": source.database.Name()}}},
+		{Key: "authenticationRestrictions", Value: []bson.M{{"clientSource": bson.A{userHost}}}},
 	})
 
 	if result.Err() != nil {
@@ -98,6 +100,209 @@ func (source *MongoSource) CreateDatabase(name string) bool {
 	return true
 }
 
+func (source *MongoSource) UseDatabase(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	names, err := source.client.ListDatabaseNames(ctx, bson.D{{Key: "name", Value: name}})
+	if err != nil || len(names) == 0 {
+		return false
+	}
+
+	source.database = source.client.Database(name)
+
+	return true
+}
+
+// UserExists tells whether the user is defined in the selected database.
+func (source MongoSource) UserExists(name string, userHost string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result struct {
+		Users []bson.Raw `bson:"users"`
+	}
+
+	err := source.database.RunCommand(ctx, bson.D{{Key: "usersInfo", Value: name}}).Decode(&result)
+
+	return err == nil && len(result.Users) > 0
+}
+
+// mongoDumpEntry is a single line of a dump - one document or index specification with the collection it belongs to.
+type mongoDumpEntry struct {
+	Collection string   `bson:"collection"`
+	Document   bson.Raw `bson:"document,omitempty"`
+	Index      bson.Raw `bson:"index,omitempty"`
+}
+
+// Dump writes indexes and documents of the selected database as lines of canonical extended JSON.
+// Indexes of a collection come before its documents, so that empty collections are dumped too.
+func (source MongoSource) Dump(w io.Writer) (bool, error) {
+	ctx := context.Background()
+
+	collections, err := source.database.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return false, err
+	}
+
+	out := bufio.NewWriter(w)
+
+	for _, collection := range collections {
+		if ok, err := source.dumpIndexes(ctx, out, collection); !ok {
+			return false, err
+		}
+
+		cursor, err := source.database.Collection(collection).Find(ctx, bson.D{})
+		if err != nil {
+			return false, err
+		}
+
+		for cursor.Next(ctx) {
+			line, err := bson.MarshalExtJSON(mongoDumpEntry{Collection: collection, Document: cursor.Current}, true, false)
+			if err != nil {
+				_ = cursor.Close(ctx)
+				return false, err
+			}
+
+			_, _ = out.Write(line)
+			_ = out.WriteByte('\n')
+		}
+
+		err = cursor.Err()
+		_ = cursor.Close(ctx)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err = out.Flush(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// dumpIndexes writes specifications of the collection's indexes, except for the default one on _id.
+func (source MongoSource) dumpIndexes(ctx context.Context, out io.Writer, collection string) (bool, error) {
+	cursor, err := source.database.Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func(cursor *mongo.Cursor) {
+		_ = cursor.Close(ctx)
+	}(cursor)
+
+	for cursor.Next(ctx) {
+		var spec bson.D
+		if err = bson.Unmarshal(cursor.Current, &spec); err != nil {
+			return false, err
+		}
+
+		// The namespace is specific to the source database; servers before 4.4 still return it
+		var index bson.D
+		var name string
+		for _, element := range spec {
+			if element.Key == "ns" {
+				continue
+			}
+			if element.Key == "name" {
+				name, _ = element.Value.(string)
+			}
+
+			index = append(index, element)
+		}
+
+		if name == "_id_" {
+			continue
+		}
+
+		raw, err := bson.Marshal(index)
+		if err != nil {
+			return false, err
+		}
+
+		line, err := bson.MarshalExtJSON(mongoDumpEntry{Collection: collection, Index: raw}, true, false)
+		if err != nil {
+			return false, err
+		}
+
+		_, _ = out.Write(line)
+		_, _ = out.Write([]byte{'\n'})
+	}
+
+	return true, cursor.Err()
+}
+
+// Restore inserts documents from a dump created by Dump into the selected database.
+// Like DROP TABLE in MySQL dumps, every restored collection is dropped first. Indexes are recreated before documents are inserted.
+func (source MongoSource) Restore(r io.Reader) (bool, error) {
+	ctx := context.Background()
+	restored := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry mongoDumpEntry
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &entry); err != nil {
+			return false, err
+		}
+
+		collection := source.database.Collection(entry.Collection)
+
+		if !restored[entry.Collection] {
+			if err := collection.Drop(ctx); err != nil {
+				return false, err
+			}
+
+			restored[entry.Collection] = true
+		}
+
+		if entry.Index != nil {
+			result := source.database.RunCommand(ctx, bson.D{
+				{Key: "createIndexes", Value: entry.Collection},
+				{Key: "indexes", Value: bson.A{entry.Index}},
+			})
+			if err := result.Err(); err != nil {
+				return false, err
+			}
+
+			continue
+		}
+
+		if _, err := collection.InsertOne(ctx, entry.Document); err != nil {
+			return false, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DropUser removes the user defined in the selected database.
+func (source MongoSource) DropUser(name string, userHost string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result := source.database.RunCommand(ctx, bson.D{{Key: "dropUser", Value: name}})
+
+	return result.Err() == nil
+}
+
+func (source MongoSource) DropDatabase(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return source.client.Database(name).Drop(ctx) == nil
+}
+
 func (source MongoSource) Close() {
 	_ = source.client.Disconnect(context.Background())
 }
@@ -1,10 +1,16 @@
 package databases
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
 	_ "github.com/go-sql-driver/mysql"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
 )
 
 type MysqlSource struct {
@@ -66,7 +72,7 @@ func (source MysqlSource) CreateUser(name string, userHost string, password stri
 }
 
 func (source *MysqlSource) CreateDatabase(name string) bool {
-	_, err := source.db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", name))
+	_, err := source.db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", name))
 	if err != nil {
 		return false
 	}
@@ -76,6 +82,456 @@ func (source *MysqlSource) CreateDatabase(name string) bool {
 	return true
 }
 
+func (source *MysqlSource) UseDatabase(name string) bool {
+	var found string
+
+	err := source.db.QueryRow("SHOW DATABASES LIKE ?", escapeMysqlLike(name)).Scan(&found)
+	if err != nil {
+		return false
+	}
+
+	source.databaseName = name
+
+	return true
+}
+
+func (source MysqlSource) UserExists(name string, userHost string) bool {
+	var count int
+
+	err := source.db.QueryRow("SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?", name, userHost).Scan(&count)
+
+	return err == nil && count > 0
+}
+
+// Dump writes the selected database as plain SQL statements, one statement per line
+// (except for table definitions, which end with a semicolon on their last line).
+// Views follow the tables; routines and triggers come last, wrapped in DELIMITER lines like in mysqldump output.
+func (source MysqlSource) Dump(w io.Writer) (bool, error) {
+	tables, err := source.queryNames(fmt.Sprintf("SHOW FULL TABLES FROM `%s` WHERE Table_type = 'BASE TABLE'", source.databaseName))
+	if err != nil {
+		return false, err
+	}
+
+	out := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(out, "-- caddyDomainManager dump of database %s\nSET FOREIGN_KEY_CHECKS=0;\n", source.databaseName)
+
+	for _, table := range tables {
+		create, err := source.showCreate(fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`", source.databaseName, table), 1)
+		if err != nil {
+			return false, err
+		}
+
+		_, _ = fmt.Fprintf(out, "DROP TABLE IF EXISTS `%s`;\n%s;\n", table, create)
+
+		if ok, err := source.dumpRows(out, table); !ok {
+			return false, err
+		}
+	}
+
+	if ok, err := source.dumpViews(out); !ok {
+		return false, err
+	}
+
+	if ok, err := source.dumpRoutines(out); !ok {
+		return false, err
+	}
+
+	_, _ = fmt.Fprintln(out, "SET FOREIGN_KEY_CHECKS=1;")
+
+	if err = out.Flush(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// dumpViews writes views ordered so that a view comes after the views it selects from.
+func (source MysqlSource) dumpViews(out io.Writer) (bool, error) {
+	views, err := source.queryNames(fmt.Sprintf("SHOW FULL TABLES FROM `%s` WHERE Table_type = 'VIEW'", source.databaseName))
+	if err != nil {
+		return false, err
+	}
+
+	creates := map[string]string{}
+	for _, view := range views {
+		create, err := source.showCreate(fmt.Sprintf("SHOW CREATE VIEW `%s`.`%s`", source.databaseName, view), 1)
+		if err != nil {
+			return false, err
+		}
+
+		creates[view] = withoutDefiner(create)
+	}
+
+	for _, view := range orderViews(creates) {
+		_, _ = fmt.Fprintf(out, "DROP VIEW IF EXISTS `%s`;\n%s;\n", view, creates[view])
+	}
+
+	return true, nil
+}
+
+// dumpRoutines writes stored procedures, functions and triggers. Their bodies may contain semicolons,
+// so they are terminated with ;; instead.
+func (source MysqlSource) dumpRoutines(out io.Writer) (bool, error) {
+	var routines []string
+	var routineTypes []string
+
+	rows, err := source.db.Query("SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? ORDER BY ROUTINE_NAME", source.databaseName)
+	if err != nil {
+		return false, err
+	}
+
+	for rows.Next() {
+		var routine, routineType string
+		if err = rows.Scan(&routine, &routineType); err != nil {
+			_ = rows.Close()
+			return false, err
+		}
+
+		routines = append(routines, routine)
+		routineTypes = append(routineTypes, routineType)
+	}
+	_ = rows.Close()
+
+	triggers, err := source.queryNames(fmt.Sprintf("SHOW TRIGGERS FROM `%s`", source.databaseName))
+	if err != nil {
+		return false, err
+	}
+
+	if len(routines) == 0 && len(triggers) == 0 {
+		return true, nil
+	}
+
+	_, _ = fmt.Fprintln(out, "DELIMITER ;;")
+
+	for i, routine := range routines {
+		// SHOW CREATE PROCEDURE and SHOW CREATE FUNCTION return the statement in the third column
+		create, err := source.showCreate(fmt.Sprintf("SHOW CREATE %s `%s`.`%s`", routineTypes[i], source.databaseName, routine), 2)
+		if err != nil {
+			return false, err
+		}
+
+		_, _ = fmt.Fprintf(out, "DROP %s IF EXISTS `%s`;;\n%s;;\n", routineTypes[i], routine, withoutDefiner(create))
+	}
+
+	for _, trigger := range triggers {
+		create, err := source.showCreate(fmt.Sprintf("SHOW CREATE TRIGGER `%s`.`%s`", source.databaseName, trigger), 2)
+		if err != nil {
+			return false, err
+		}
+
+		_, _ = fmt.Fprintf(out, "DROP TRIGGER IF EXISTS `%s`;;\n%s;;\n", trigger, withoutDefiner(create))
+	}
+
+	_, _ = fmt.Fprintln(out, "DELIMITER ;")
+
+	return true, nil
+}
+
+// queryNames returns the first column of every row of the query.
+func (source MysqlSource) queryNames(query string) ([]string, error) {
+	rows, err := source.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var names []string
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		names = append(names, values[0].String)
+	}
+
+	return names, rows.Err()
+}
+
+// showCreate runs a SHOW CREATE statement and returns given column of its only row.
+func (source MysqlSource) showCreate(query string, column int) (string, error) {
+	rows, err := source.db.Query(query)
+	if err != nil {
+		return "", err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", err
+		}
+
+		return "", sql.ErrNoRows
+	}
+
+	if err = rows.Scan(pointers...); err != nil {
+		return "", err
+	}
+
+	// The statement is NULL without privileges to see the definition
+	if column >= len(values) || !values[column].Valid {
+		return "", fmt.Errorf("no access to the definition returned by %s", query)
+	}
+
+	return values[column].String, nil
+}
+
+func (source MysqlSource) dumpRows(out io.Writer, table string) (bool, error) {
+	rows, err := source.db.Query(fmt.Sprintf("SELECT * FROM `%s`.`%s`", source.databaseName, table))
+	if err != nil {
+		return false, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return false, err
+		}
+
+		_, _ = fmt.Fprintln(out, mysqlInsert(table, values))
+	}
+
+	return true, rows.Err()
+}
+
+// mysqlInsert renders a row as a single line INSERT statement, NULL for nil values.
+func mysqlInsert(table string, values []sql.RawBytes) string {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		if value == nil {
+			literals[i] = "NULL"
+		} else {
+			literals[i] = quoteMysqlValue(value)
+		}
+	}
+
+	return fmt.Sprintf("INSERT INTO `%s` VALUES (%s);", table, strings.Join(literals, ","))
+}
+
+// Restore executes a dump created by Dump in the selected database.
+func (source MysqlSource) Restore(r io.Reader) (bool, error) {
+	ctx := context.Background()
+
+	// USE only affects a single connection, so the whole restore runs on one
+	conn, err := source.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf("USE `%s`", source.databaseName)); err != nil {
+		return false, err
+	}
+
+	err = readMysqlStatements(r, func(statement string) error {
+		_, err := conn.ExecContext(ctx, statement)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// readMysqlStatements splits a dump into statements, passing each one to exec. Like the mysql client,
+// it understands DELIMITER lines; statements ending with a custom delimiter are passed without it.
+// Delimiters inside quoted strings and identifiers, which may span lines, do not end a statement.
+func readMysqlStatements(r io.Reader, exec func(statement string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	delimiter := ";"
+	var quote byte
+
+	var statement strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if statement.Len() == 0 {
+			if len(line) == 0 || strings.HasPrefix(line, "--") {
+				continue
+			}
+
+			if strings.HasPrefix(line, "DELIMITER ") {
+				delimiter = strings.TrimSpace(strings.TrimPrefix(line, "DELIMITER "))
+				continue
+			}
+		}
+
+		if statement.Len() > 0 {
+			statement.WriteByte('\n')
+		}
+		statement.WriteString(line)
+
+		if quote = mysqlQuoteAfter(line, quote); quote != 0 || !strings.HasSuffix(line, delimiter) {
+			continue
+		}
+
+		query := statement.String()
+		if delimiter != ";" {
+			query = strings.TrimSuffix(query, delimiter)
+		}
+
+		if err := exec(query); err != nil {
+			return err
+		}
+
+		statement.Reset()
+	}
+
+	return scanner.Err()
+}
+
+// mysqlQuoteAfter returns the quote character still open at the end of the line, 0 if there is none.
+// Backslashes escape the next character inside strings, but not inside `identifiers`.
+// Quotes in comments (i.e. of routine bodies) are ignored.
+func mysqlQuoteAfter(line string, quote byte) byte {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == 0 && (c == '#' || strings.HasPrefix(line[i:], "-- ")):
+			return 0
+		case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+			quote = c
+		case quote != 0 && quote != '`' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+
+	return quote
+}
+
+func (source MysqlSource) DropUser(name string, userHost string) bool {
+	_, err := source.db.Exec(fmt.Sprintf("DROP USER IF EXISTS '%s'@'%s'", name, userHost))
+
+	return err == nil
+}
+
+func (source MysqlSource) DropDatabase(name string) bool {
+	_, err := source.db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", name))
+
+	return err == nil
+}
+
 func (source MysqlSource) Close() {
 	_ = source.db.Close()
 }
+
+func quoteMysqlValue(value []byte) string {
+	sb := strings.Builder{}
+	sb.Grow(len(value) + 2)
+	sb.WriteByte('\'')
+
+	for _, b := range value {
+		switch b {
+		case 0:
+			sb.WriteString(`\0`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case 0x1a:
+			sb.WriteString(`\Z`)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+
+	sb.WriteByte('\'')
+
+	return sb.String()
+}
+
+// escapeMysqlLike escapes wildcards, so that LIKE matches the name exactly.
+func escapeMysqlLike(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(name)
+}
+
+var mysqlDefinerPattern = regexp.MustCompile("DEFINER=`(?:[^`]|``)*`@`(?:[^`]|``)*` ")
+
+// withoutDefiner removes the DEFINER clause from a CREATE statement, so that the restored object
+// belongs to the user restoring it - the original definer may not exist on the target server.
+func withoutDefiner(create string) string {
+	return mysqlDefinerPattern.ReplaceAllString(create, "")
+}
+
+// orderViews sorts views by name, moving every view after the views its definition refers to.
+func orderViews(creates map[string]string) []string {
+	var names []string
+	for name := range creates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []string
+	added := map[string]bool{}
+
+	var add func(name string, visiting map[string]bool)
+	add = func(name string, visiting map[string]bool) {
+		if added[name] || visiting[name] {
+			return
+		}
+		visiting[name] = true
+
+		for _, other := range names {
+			if other != name && strings.Contains(creates[name], "`"+other+"`") {
+				add(other, visiting)
+			}
+		}
+
+		added[name] = true
+		ordered = append(ordered, name)
+	}
+
+	for _, name := range names {
+		add(name, map[string]bool{})
+	}
+
+	return ordered
+}
@@ -0,0 +1,132 @@
+package databases
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMysqlStatements(t *testing.T) {
+	dump := "-- caddyDomainManager dump of database test\n" +
+		"SET FOREIGN_KEY_CHECKS=0;\n" +
+		"CREATE TABLE `a` (\n  `id` int\n);\n" +
+		"INSERT INTO `a` VALUES ('x;\\n');\n" +
+		"DELIMITER ;;\n" +
+		"CREATE PROCEDURE `p`()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND;;\n" +
+		"DELIMITER ;\n" +
+		"SET FOREIGN_KEY_CHECKS=1;\n"
+
+	expected := []string{
+		"SET FOREIGN_KEY_CHECKS=0;",
+		"CREATE TABLE `a` (\n  `id` int\n);",
+		"INSERT INTO `a` VALUES ('x;\\n');",
+		"CREATE PROCEDURE `p`()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND",
+		"SET FOREIGN_KEY_CHECKS=1;",
+	}
+
+	var statements []string
+	err := readMysqlStatements(strings.NewReader(dump), func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Dump split incorrectly, expected no error, got %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Dump split incorrectly, expected %q, got %q", expected, statements)
+	}
+}
+
+func TestWithoutDefiner(t *testing.T) {
+	tables := []struct {
+		input    string
+		expected string
+	}{
+		{"CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`localhost` SQL SECURITY DEFINER VIEW `v` AS select 1",
+			"CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1"},
+		{"CREATE DEFINER=`app``s`@`%` PROCEDURE `p`() SELECT 1", "CREATE PROCEDURE `p`() SELECT 1"},
+		{"CREATE TABLE `t` (`id` int)", "CREATE TABLE `t` (`id` int)"},
+	}
+
+	for _, table := range tables {
+		if got := withoutDefiner(table.input); got != table.expected {
+			t.Errorf("Definer removed incorrectly, expected %s, got %s", table.expected, got)
+		}
+	}
+}
+
+func TestOrderViews(t *testing.T) {
+	creates := map[string]string{
+		"a_totals": "CREATE VIEW `a_totals` AS select * from `db`.`b_orders`",
+		"b_orders": "CREATE VIEW `b_orders` AS select * from `db`.`c_base`",
+		"c_base":   "CREATE VIEW `c_base` AS select * from `db`.`orders`",
+		"d_other":  "CREATE VIEW `d_other` AS select 1",
+	}
+	expected := []string{"c_base", "b_orders", "a_totals", "d_other"}
+
+	if got := orderViews(creates); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Views ordered incorrectly, expected %v, got %v", expected, got)
+	}
+}
+
+func TestEscapeMysqlLike(t *testing.T) {
+	tables := []struct {
+		input    string
+		expected string
+	}{
+		{"example_com", `example\_com`},
+		{"100%", `100\%`},
+		{`a\b`, `a\\b`},
+		{"plain", "plain"},
+	}
+
+	for _, table := range tables {
+		if got := escapeMysqlLike(table.input); got != table.expected {
+			t.Errorf("LIKE pattern escaped incorrectly, expected %s, got %s", table.expected, got)
+		}
+	}
+}
+
+func TestMysqlDumpRoundTrip(t *testing.T) {
+	rows := [][]sql.RawBytes{
+		{sql.RawBytes("1"), sql.RawBytes("ends with;")},
+		{sql.RawBytes("2"), sql.RawBytes("line;\nbreak;")},
+		{sql.RawBytes("3"), sql.RawBytes(`it's \ "quoted";`)},
+		{sql.RawBytes("4"), nil},
+	}
+
+	// Table definitions keep literal newlines, i.e. in comments
+	create := "CREATE TABLE `notes` (\n  `id` int,\n  `body` text COMMENT 'first;\nsecond'\n);"
+	routine := "CREATE PROCEDURE `p`()\nBEGIN\n  -- don't split here;\n  SELECT 'a;\nb';\nEND"
+
+	expected := []string{create}
+	dump := "-- caddyDomainManager dump of database test\n" + create + "\n"
+	for _, row := range rows {
+		insert := mysqlInsert("notes", row)
+		expected = append(expected, insert)
+		dump += insert + "\n"
+	}
+	expected = append(expected, routine)
+	dump += "DELIMITER ;;\n" + routine + ";;\nDELIMITER ;\n"
+
+	var statements []string
+	err := readMysqlStatements(strings.NewReader(dump), func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Dump read incorrectly, expected no error, got %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Dump read incorrectly, expected %q, got %q", expected, statements)
+	}
+
+	if statements[3] != "INSERT INTO `notes` VALUES ('3','it\\'s \\\\ \"quoted\";');" {
+		t.Errorf("Row quoted incorrectly, got %s", statements[3])
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/databases"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"path"
+)
+
+var backupDirectory string
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage databases of existing websites",
+	Long:  `Manage databases of websites created by createSite, using the connection details stored in the sites registry.`,
+}
+
+// lookupSite finds the site in the registry, exiting if it is not there.
+func lookupSite(envConfig utils.EnvironmentConfig, domain string) structs.SiteRecord {
+	registry := loadRegistry(envConfig)
+
+	record, err := registry.Get(domain)
+	if err != nil {
+		println(fmt.Sprintf("Site %s is not registered", domain))
+		os.Exit(ExitUsage)
+	}
+
+	return record
+}
+
+// connectSiteDatabase connects to the site's database server as the administrator.
+func connectSiteDatabase(record structs.SiteRecord) databases.DatabaseSource {
+	if record.Database == nil {
+		println(fmt.Sprintf("Site %s has no database", record.DomainName))
+		os.Exit(ExitDatabaseConfig)
+	}
+
+	if err := resolveDatabaseAdmin(record.Database.Type); err != nil {
+		println(err.Error())
+		os.Exit(ExitDatabaseConfig)
+	}
+
+	source := newDatabaseSource(record.Database.Type, record.Database.Host, record.Database.Port)
+	if ok := source.Connect(); !ok {
+		println("There was an error while connecting to the database server")
+		os.Exit(ExitDatabaseConnect)
+	}
+
+	return source
+}
+
+// backupsPath returns the directory with backups - from the flag, config or SERVER_FILES_DIR/backups.
+func backupsPath(envConfig utils.EnvironmentConfig) string {
+	if dir := viper.GetString("backups.directory"); len(dir) > 0 {
+		return dir
+	}
+
+	return path.Join(envConfig.ServerFiles, "backups")
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+
+	dbCmd.PersistentFlags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	dbCmd.PersistentFlags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	dbCmd.PersistentFlags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+	dbCmd.PersistentFlags().StringVarP(&backupDirectory, "dir", "d", "", "Directory with backups. Optional, default taken from config or SERVER_FILES_DIR/backups.")
+
+	viper.BindPFlag("backups.directory", dbCmd.PersistentFlags().Lookup("dir"))
+}
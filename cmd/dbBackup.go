@@ -0,0 +1,114 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+var backupRetention int
+
+// dbBackupCmd represents the db backup command
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup <domain name>",
+	Short: "Create a compressed dump of the website's database",
+	Long:  `Dump the website's database (mysql or mongo) into a gzipped file in the backups directory, removing the oldest backups above the retention limit.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		domain := domainArg(args[0])
+
+		record := lookupSite(envConfig, domain)
+		source := connectSiteDatabase(record)
+		defer source.Close()
+
+		if ok := source.UseDatabase(record.Database.Name); !ok {
+			println(fmt.Sprintf("Database %s does not exist", record.Database.Name))
+			os.Exit(ExitDatabaseConnect)
+		}
+
+		directory := backupsPath(envConfig)
+		if err := os.MkdirAll(directory, 0700); err != nil {
+			println(fmt.Sprintf("Could not create backups directory %s: %s", directory, err.Error()))
+			os.Exit(ExitFileStructure)
+		}
+
+		backupPath := path.Join(directory, utils.BackupFileName(domain, record.Database.Type, time.Now()))
+
+		if ok, err := dumpToFile(backupPath, source.Dump); !ok {
+			_ = os.Remove(backupPath)
+			println(fmt.Sprintf("There was an error while dumping the database: %s", err.Error()))
+			os.Exit(ExitDatabaseConnect)
+		}
+
+		println(fmt.Sprintf("[%s] Backed up database %s to %s", domain, record.Database.Name, backupPath))
+
+		removed, err := utils.PruneBackups(directory, domain, viper.GetInt("backups.retention"))
+		if err != nil {
+			println(fmt.Sprintf("Warning: could not remove old backups: %s", err.Error()))
+		}
+
+		for _, name := range removed {
+			println(fmt.Sprintf("[%s] Removed old backup %s", domain, name))
+		}
+	},
+}
+
+// dumpToFile writes gzip compressed output of the dump function to a new file.
+func dumpToFile(filePath string, dump func(w io.Writer) (bool, error)) (bool, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return false, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	compressed := gzip.NewWriter(file)
+
+	if ok, err := dump(compressed); !ok {
+		return false, err
+	}
+
+	if err = compressed.Close(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func init() {
+	dbCmd.AddCommand(dbBackupCmd)
+
+	dbBackupCmd.Flags().IntVarP(&backupRetention, "keep", "k", 7, "Number of newest backups of the website to keep, 0 keeps all of them")
+
+	viper.BindPFlag("backups.retention", dbBackupCmd.Flags().Lookup("keep"))
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	restoreInto  string
+	restoreForce bool
+)
+
+// dbRestoreCmd represents the db restore command
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <domain name> <backup file>",
+	Short: "Restore the website's database from a backup",
+	Long: `Restore the website's database from a backup created by db backup. The file may be given as a path or a name inside the backups directory.
+Use --into to restore into a fresh database instead, i.e. to verify the backup without touching the website.
+An existing database is only restored into with --force.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		domain := domainArg(args[0])
+
+		backupPath := args[1]
+		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+			backupPath = path.Join(backupsPath(envConfig), args[1])
+		}
+
+		record := lookupSite(envConfig, domain)
+		source := connectSiteDatabase(record)
+		defer source.Close()
+
+		databaseName := record.Database.Name
+		if len(restoreInto) > 0 {
+			databaseName = restoreInto
+
+			if source.UseDatabase(databaseName) && !restoreForce {
+				println(fmt.Sprintf("Database %s already exists, use --force to restore into it", databaseName))
+				os.Exit(ExitUsage)
+			}
+
+			if ok := source.CreateDatabase(databaseName); !ok {
+				println("There was an error while creating the database")
+				os.Exit(ExitDatabaseCreate)
+			}
+		} else if ok := source.UseDatabase(databaseName); !ok {
+			println(fmt.Sprintf("Database %s does not exist", databaseName))
+			os.Exit(ExitDatabaseConnect)
+		}
+
+		if ok, err := restoreFromFile(backupPath, source.Restore); !ok {
+			println(fmt.Sprintf("There was an error while restoring the database: %s", err.Error()))
+			os.Exit(ExitDatabaseCreate)
+		}
+
+		println(fmt.Sprintf("[%s] Restored database %s from %s", domain, databaseName, backupPath))
+	},
+}
+
+// restoreFromFile feeds the restore function with a backup file, decompressing it if needed.
+func restoreFromFile(filePath string, restore func(r io.Reader) (bool, error)) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var reader io.Reader = file
+
+	if strings.HasSuffix(filePath, ".gz") {
+		decompressed, err := gzip.NewReader(file)
+		if err != nil {
+			return false, err
+		}
+		defer func(decompressed *gzip.Reader) {
+			_ = decompressed.Close()
+		}(decompressed)
+
+		reader = decompressed
+	}
+
+	return restore(reader)
+}
+
+func init() {
+	dbCmd.AddCommand(dbRestoreCmd)
+
+	dbRestoreCmd.Flags().StringVar(&restoreInto, "into", "", "Restore into a new database with given name instead of the website's one")
+	dbRestoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Restore with --into even if the database already exists")
+}
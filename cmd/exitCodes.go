@@ -0,0 +1,78 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import "errors"
+
+// Exit codes, distinct per failure class, so scripts can tell what went wrong.
+const (
+	ExitOk              = 0
+	ExitGeneric         = 1
+	ExitUsage           = 2 // Missing environment variable, incorrect flag or argument
+	ExitFileStructure   = 3
+	ExitCaddyfileExists = 4
+	ExitTemplateMissing = 5
+	ExitEnableSite      = 6
+	ExitDatabaseConfig  = 7 // Missing administrator credentials, incorrect host
+	ExitDatabaseConnect = 8
+	ExitDatabaseCreate  = 9
+	ExitDatabaseUser    = 10
+	ExitCaddyReload     = 11
+	ExitSystemUser      = 12
+	ExitPartialFailure  = 13 // Some of the websites in a batch failed
+	ExitCaddyInvalid    = 14 // Changed configuration did not pass caddy validate
+	ExitPhpPool         = 15
+	ExitService         = 16 // systemd unit could not be installed, enabled or started
+	ExitNoFreePort      = 17 // Every port of the configured range is taken
+	ExitRegistry        = 18 // Sites registry could not be read or written
+)
+
+// stepError carries the exit code of the step which failed.
+type stepError struct {
+	Code int
+	Err  error
+}
+
+func (e stepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e stepError) Unwrap() error {
+	return e.Err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return stepError{Code: code, Err: err}
+}
+
+// exitCode returns the code to exit with after err, ExitGeneric for errors of unknown class.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+
+	var step stepError
+	if errors.As(err, &step) {
+		return step.Code
+	}
+
+	return ExitGeneric
+}
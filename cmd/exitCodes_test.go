@@ -0,0 +1,55 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tables := []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitOk},
+		{errors.New("unknown"), ExitGeneric},
+		{withExitCode(ExitCaddyReload, errors.New("reload failed")), ExitCaddyReload},
+		{fmt.Errorf("step: %w", withExitCode(ExitPhpPool, errors.New("pool failed"))), ExitPhpPool},
+		{withExitCode(ExitUsage, nil), ExitOk},
+	}
+
+	for _, table := range tables {
+		if code := exitCode(table.err); code != table.expected {
+			t.Errorf("Exit code of %v incorrect, expected %d, got %d", table.err, table.expected, code)
+		}
+	}
+}
+
+func TestWithExitCodeUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := withExitCode(ExitService, cause)
+
+	if !errors.Is(err, cause) {
+		t.Errorf("Step error should wrap its cause, expected %v, got %v", cause, errors.Unwrap(err))
+	}
+
+	if err.Error() != cause.Error() {
+		t.Errorf("Step error message incorrect, expected %s, got %s", cause.Error(), err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// listSitesCmd represents the listSites command
+var listSitesCmd = &cobra.Command{
+	Use:   "listSites",
+	Short: "List registered websites",
+	Long: `List websites from the sites registry with their type, files root and state.
+Websites whose files root differs from the one given by the configured directory layout are marked, as they were created with another layout.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		registry := loadRegistry(envConfig)
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(table, "DOMAIN\tTYPE\tENABLED\tDATABASE\tFILES ROOT")
+
+		for _, domain := range registry.Domains() {
+			record := registry.Sites[domain]
+			site := structs.SiteConfigFromRecord(record)
+
+			database := "-"
+			if record.Database != nil {
+				database = fmt.Sprintf("%s/%s", strings.ToLower(string(record.Database.Type)), record.Database.Name)
+			}
+
+			filesRoot := record.FilesRoot
+			if expected, err := site.DomainRootPath(envConfig); err == nil && expected != record.FilesRoot {
+				filesRoot += " (layout: " + expected + ")"
+			}
+
+			_, _ = fmt.Fprintf(table, "%s\t%s\t%t\t%s\t%s\n", site.Name(), strings.ToLower(string(record.Type)), site.IsEnabled(envConfig), database, filesRoot)
+		}
+
+		_ = table.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listSitesCmd)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	regenerateAll bool
+	regenerateYes bool
+)
+
+// regenerateCmd represents the regenerate command
+var regenerateCmd = &cobra.Command{
+	Use:   "regenerate [domain name]",
+	Short: "Render Caddyfiles of existing websites again",
+	Long: `Render Caddyfiles of the website (or all registered websites with --all) from their current templates, using the type
+and variables recorded in the sites registry. A unified diff is shown for every changed Caddyfile, which is written after
+confirmation (or right away with --yes). The whole Caddy config is then validated - if it is not valid, all written
+Caddyfiles are put back - and Caddy is reloaded once.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if regenerateAll {
+			return cobra.NoArgs(cmd, args)
+		}
+
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		registry := loadRegistry(envConfig)
+
+		domains := registry.Domains()
+		if !regenerateAll {
+			domain := domainArg(args[0])
+			if _, err := registry.Get(domain); err != nil {
+				println(fmt.Sprintf("Site %s is not registered", domain))
+				os.Exit(ExitGeneric)
+			}
+
+			domains = []string{domain}
+		}
+
+		// Previous content of written Caddyfiles, to put back if the config turns out invalid
+		written := map[string][]byte{}
+		failed := 0
+		input := bufio.NewReader(os.Stdin)
+
+		for _, domain := range domains {
+			record := registry.Sites[domain]
+			site := structs.SiteConfigFromRecord(record)
+
+			rendered, err := site.RenderConfig(envConfig)
+			if err != nil {
+				println(fmt.Sprintf("[%s] Could not render Caddyfile: %s", domain, err.Error()))
+				failed++
+				continue
+			}
+
+			current, _ := ioutil.ReadFile(record.Caddyfile)
+
+			diff := utils.UnifiedDiff(record.Caddyfile, record.Caddyfile+" (regenerated)", string(current), string(rendered), 3)
+			if len(diff) == 0 {
+				println(fmt.Sprintf("[%s] Caddyfile is up to date", domain))
+				continue
+			}
+
+			fmt.Print(diff)
+
+			if !regenerateYes && !confirm(input, fmt.Sprintf("Write Caddyfile of %s?", domain)) {
+				println(fmt.Sprintf("[%s] Skipped", domain))
+				continue
+			}
+
+			if _, err = site.UpdateConfig(envConfig); err != nil {
+				println(fmt.Sprintf("[%s] Could not write Caddyfile: %s", domain, err.Error()))
+				failed++
+				continue
+			}
+
+			written[record.Caddyfile] = current
+			println(fmt.Sprintf("[%s] Caddyfile written", domain))
+		}
+
+		if len(written) > 0 {
+			if ok, err := (structs.SiteConfig{}).ValidateCaddy(envConfig); !ok {
+				println(err.Error())
+
+				for caddyfile, previous := range written {
+					if err = ioutil.WriteFile(caddyfile, previous, 0775); err != nil {
+						println(fmt.Sprintf("Warning: could not restore %s: %s", caddyfile, err.Error()))
+					}
+				}
+
+				println("Previous Caddyfiles were restored")
+				os.Exit(ExitCaddyInvalid)
+			}
+
+			if ok, _ := (structs.SiteConfig{}).ReloadCaddy(envConfig); !ok {
+				os.Exit(ExitCaddyReload)
+			}
+		}
+
+		if failed > 0 {
+			os.Exit(ExitPartialFailure)
+		}
+	},
+}
+
+// confirm asks a yes/no question on stderr, reading the answer from input. No is the default.
+func confirm(input *bufio.Reader, question string) bool {
+	println(fmt.Sprintf("%s [y/N]", question))
+
+	answer, err := input.ReadString('\n')
+	if err != nil && len(answer) == 0 {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	rootCmd.AddCommand(regenerateCmd)
+
+	regenerateCmd.Flags().BoolVar(&regenerateAll, "all", false, "Regenerate Caddyfiles of all registered websites")
+	regenerateCmd.Flags().BoolVarP(&regenerateYes, "yes", "y", false, "Write changed Caddyfiles without asking")
+}
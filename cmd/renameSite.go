@@ -0,0 +1,308 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+var (
+	renameRedirect bool
+	renameDatabase bool
+)
+
+// renameSiteCmd represents the renameSite command
+var renameSiteCmd = &cobra.Command{
+	Use:   "renameSite <old domain> <new domain>",
+	Short: "Move a website to a new domain",
+	Long: `Move a website to a new domain: its files root is moved to the place of the new domain in the directory layout,
+a Caddyfile for the new domain replaces the old one in sites-all and sites-enabled, and the sites registry is updated.
+With --redirect, the old domain keeps permanently redirecting to the new one. With --rename-db, the database is copied
+to a new database and user named after the new domain (with the same password), credentials in .env or wp-config.php are rewritten,
+and the old ones are dropped. php-fpm pool, systemd unit and Caddyfile of the new domain are prepared before the files are moved;
+the application is stopped for the move and started as the new unit afterwards, which also takes over starting on boot, and the old unit is removed.
+If any step fails, all previous ones are undone.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		oldDomain := domainArg(args[0])
+
+		newDomain, displayName, err := utils.NormalizeDomain(args[1])
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitUsage)
+		}
+
+		registry := loadRegistry(envConfig)
+
+		record, err := registry.Get(oldDomain)
+		if err != nil {
+			println(fmt.Sprintf("Site %s is not registered", oldDomain))
+			os.Exit(ExitGeneric)
+		}
+
+		if owner, ok := registry.Owner(newDomain); ok && owner != oldDomain {
+			println(fmt.Sprintf("%s is already served by %s", newDomain, owner))
+			os.Exit(ExitUsage)
+		}
+
+		site := structs.SiteConfigFromRecord(record)
+
+		renamed, err := site.Renamed(envConfig, newDomain, displayName)
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitFileStructure)
+		}
+
+		if site.Tls.Mode == structs.TlsCustom {
+			println(fmt.Sprintf("Warning: [%s] custom certificate of %s does not cover the new domain, certificate handling is left to the template", newDomain, oldDomain))
+		}
+
+		if renameRedirect {
+			renamed.RedirectFrom = append(renamed.RedirectFrom, oldDomain)
+		}
+
+		// Subdomain sites of the nested layout live inside their parent's files root
+		for _, domain := range registry.SitesInside(site.FilesRoot()) {
+			println(fmt.Sprintf("Files of %s are inside %s, move them first", domain, site.FilesRoot()))
+			os.Exit(ExitFileStructure)
+		}
+
+		var undo rollback
+
+		fail := func(err error) {
+			println(err.Error())
+			undo.run(oldDomain)
+			os.Exit(exitCode(err))
+		}
+
+		// Everything the new domain needs is staged first, so the old website keeps working until its files are moved
+		if len(site.PhpSocket) > 0 {
+			if err = createSitePhpPool(&renamed, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		services := structs.SystemctlRunner{Command: viper.GetString("systemd.systemctl")}
+
+		if len(site.Service) > 0 {
+			if ok, err := renamed.CreateSystemdUnit(envConfig, systemdSettings(), services); !ok {
+				fail(withExitCode(ExitService, fmt.Errorf("could not install systemd unit: %w", err)))
+			}
+
+			undo.add(func() error {
+				_, err := renamed.RemoveSystemdUnit(systemdSettings(), services)
+				return err
+			})
+
+			println(fmt.Sprintf("[%s] Installed systemd unit %s", newDomain, renamed.Service))
+		}
+
+		if err = createSiteCaddyfile(envConfig, &renamed); err != nil {
+			fail(err)
+		}
+
+		undo.add(func() error {
+			_, err := renamed.RemoveConfig(envConfig)
+			return err
+		})
+
+		updated := renamed.Record()
+		updated.Database = record.Database
+
+		if renameDatabase && record.Database != nil {
+			database, err := copySiteDatabase(renamed, *record.Database, record.Database.Password)
+			if err != nil {
+				fail(err)
+			}
+
+			undo.add(func() error {
+				return dropSiteDatabase(*database)
+			})
+
+			updated.Database = database
+		}
+
+		// The application can't keep running from files which are moved away
+		if len(site.Service) > 0 {
+			if err = services.Stop(site.Service); err != nil {
+				fail(withExitCode(ExitService, fmt.Errorf("could not stop %s: %w", site.Service, err)))
+			}
+
+			undo.add(func() error {
+				return services.Start(site.Service)
+			})
+
+			// Starting on boot moves to the new unit
+			if services.IsEnabled(site.Service) {
+				if err = services.Disable(site.Service); err != nil {
+					fail(withExitCode(ExitService, fmt.Errorf("could not disable %s: %w", site.Service, err)))
+				}
+
+				undo.add(func() error {
+					return services.Enable(site.Service)
+				})
+
+				if err = services.Enable(renamed.Service); err != nil {
+					fail(withExitCode(ExitService, fmt.Errorf("could not enable %s: %w", renamed.Service, err)))
+				}
+
+				undo.add(func() error {
+					return services.Disable(renamed.Service)
+				})
+			}
+		}
+
+		if ok, err := site.MoveFileStructure(envConfig, renamed); !ok {
+			fail(withExitCode(ExitFileStructure, fmt.Errorf("could not move %s to %s: %w", site.FilesRoot(), renamed.FilesRoot(), err)))
+		}
+
+		undo.add(func() error {
+			_, err := renamed.MoveFileStructure(envConfig, site)
+			return err
+		})
+
+		println(fmt.Sprintf("[%s] Moved files from %s to %s", newDomain, site.FilesRoot(), renamed.FilesRoot()))
+
+		// The old database is dropped later, so the website has to use the new one from now on
+		if updated.Database != record.Database {
+			changed, err := renamed.RewriteDatabaseCredentials(*record.Database, *updated.Database)
+
+			undo.add(func() error {
+				_, err := renamed.RewriteDatabaseCredentials(*updated.Database, *record.Database)
+				writeDatabaseInfo(renamed, *record.Database)
+				return err
+			})
+
+			if err != nil {
+				fail(withExitCode(ExitFileStructure, fmt.Errorf("could not rewrite database credentials: %w", err)))
+			} else if len(changed) == 0 {
+				fail(withExitCode(ExitFileStructure, fmt.Errorf("credentials of database %s were not found in %s, rename the website without --rename-db", record.Database.Name, strings.Join(structs.CredentialFiles, ", "))))
+			}
+
+			for _, file := range changed {
+				println(fmt.Sprintf("[%s] Rewrote database credentials in %s", newDomain, file))
+			}
+
+			writeDatabaseInfo(renamed, *updated.Database)
+		}
+
+		enabled := site.IsEnabled(envConfig)
+		if enabled {
+			if ok, err := site.DisableSite(envConfig); !ok {
+				fail(withExitCode(ExitEnableSite, err))
+			}
+
+			undo.add(func() error {
+				_, err := site.EnableSite(envConfig)
+				return err
+			})
+
+			if err = enableSite(envConfig, &renamed); err != nil {
+				fail(err)
+			}
+
+			undo.add(func() error {
+				_, err := renamed.DisableSite(envConfig)
+				return err
+			})
+		}
+
+		if ok, err := renamed.ValidateCaddy(envConfig); !ok {
+			fail(withExitCode(ExitCaddyInvalid, err))
+		}
+
+		registerSite(envConfig, updated)
+		if err = unregisterSite(envConfig, oldDomain); err != nil {
+			println(fmt.Sprintf("Warning: could not remove %s from the sites registry: %s", oldDomain, err.Error()))
+		}
+
+		undo.add(func() error {
+			registerSite(envConfig, record)
+			return unregisterSite(envConfig, newDomain)
+		})
+
+		if enabled {
+			if ok, err := renamed.ReloadCaddy(envConfig); !ok {
+				fail(withExitCode(ExitCaddyReload, err))
+			}
+		}
+
+		// Caddy serves the new domain, so old resources can go
+		if _, err = site.RemoveConfig(envConfig); err != nil && !os.IsNotExist(err) {
+			println(fmt.Sprintf("Warning: could not remove %s: %s", record.Caddyfile, err.Error()))
+		}
+
+		if removed, err := site.RemovePhpPool(phpPoolSettings()); err != nil {
+			println(fmt.Sprintf("Warning: could not remove php-fpm pool of %s: %s", oldDomain, err.Error()))
+		} else if removed {
+			if _, err = structs.ReloadPhpFpm(phpPoolSettings()); err != nil {
+				println(fmt.Sprintf("Warning: could not reload php-fpm: %s", err.Error()))
+			}
+		}
+
+		if updated.Database != record.Database {
+			if err = dropSiteDatabase(*record.Database); err != nil {
+				println(fmt.Sprintf("Warning: could not drop old database %s: %s", record.Database.Name, err.Error()))
+			} else {
+				println(fmt.Sprintf("[%s] Dropped old database %s and user %s", newDomain, record.Database.Name, record.Database.User))
+			}
+		}
+
+		if len(site.Service) > 0 {
+			oldService := site.Service
+			if _, err = site.RemoveSystemdUnit(systemdSettings(), services); err != nil {
+				println(fmt.Sprintf("Warning: could not remove systemd unit %s: %s", oldService, err.Error()))
+			}
+
+			if err = services.Start(renamed.Service); err != nil {
+				println(fmt.Sprintf("Warning: [%s] could not start %s: %s", newDomain, renamed.Service, err.Error()))
+			}
+
+			println(fmt.Sprintf("[%s] Application runs as %s, old unit %s was removed", newDomain, renamed.Service, oldService))
+		}
+
+		if len(site.SystemUser) > 0 {
+			println(fmt.Sprintf("[%s] Files are still owned by system user %s", newDomain, site.SystemUser))
+		}
+
+		println(fmt.Sprintf("[%s] Website %s moved to %s", newDomain, oldDomain, newDomain))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameSiteCmd)
+
+	renameSiteCmd.Flags().BoolVar(&renameRedirect, "redirect", false, "Keep the old domain, permanently redirecting to the new one")
+	renameSiteCmd.Flags().BoolVar(&renameDatabase, "rename-db", false, "Move the database and its user to names derived from the new domain")
+
+	renameSiteCmd.Flags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	renameSiteCmd.Flags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	renameSiteCmd.Flags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+}
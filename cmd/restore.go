@@ -0,0 +1,265 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	copyDirs "github.com/otiai10/copy"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Recreate a website from an archive",
+	Long: `Recreate a website packed by the archive command, i.e. on another server. The website goes through the same steps as in createSite:
+the files root is recreated from archived files only (no template is copied over them), the site gets its own system user, php-fpm pool or systemd unit when configured,
+the archived Caddyfile is installed with paths rewritten to the new location, and a new database user with fresh credentials is created
+and filled with the archived dump. If any step fails, all previous ones are undone.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		workDir, err := ioutil.TempDir("", "cdm-restore-")
+		if err != nil {
+			println(fmt.Sprintf("Could not create a working directory: %s", err.Error()))
+			os.Exit(ExitGeneric)
+		}
+		defer func(workDir string) {
+			_ = os.RemoveAll(workDir)
+		}(workDir)
+
+		// os.Exit skips deferred calls
+		exit := func(code int) {
+			_ = os.RemoveAll(workDir)
+			os.Exit(code)
+		}
+
+		if err = extractSiteArchive(args[0], workDir); err != nil {
+			println(fmt.Sprintf("There was an error while unpacking the archive: %s", err.Error()))
+			exit(ExitGeneric)
+		}
+
+		manifest, err := structs.ReadSiteArchiveManifest(path.Join(workDir, structs.ArchiveManifestName))
+		if err != nil {
+			println(fmt.Sprintf("The archive has no valid manifest: %s", err.Error()))
+			exit(ExitGeneric)
+		}
+
+		archived := manifest.Site
+
+		// The manifest comes from outside, so it is checked like command line arguments
+		domain, displayName, err := utils.NormalizeDomain(archived.DomainName)
+		if err != nil {
+			println(fmt.Sprintf("The archive has an invalid domain: %s", err.Error()))
+			exit(ExitUsage)
+		}
+
+		programType, err := utils.GetProgramType(string(archived.Type))
+		if err != nil {
+			println(fmt.Sprintf("The archive has an invalid type: %s", err.Error()))
+			exit(ExitUsage)
+		}
+
+		dumpPath := path.Join(workDir, manifest.DatabaseDump)
+		if len(manifest.DatabaseDump) > 0 && !strings.HasPrefix(dumpPath, workDir+"/") {
+			println(fmt.Sprintf("The archive has an invalid database dump path %s", manifest.DatabaseDump))
+			exit(ExitUsage)
+		}
+
+		var undo rollback
+
+		fail := func(err error) {
+			println(err.Error())
+			undo.run(domain)
+			exit(exitCode(err))
+		}
+
+		// Everything but what belongs to the old server is kept, so the record matches the archived Caddyfile
+		restored := structs.SiteConfigFromRecord(archived)
+		restored.Type = programType
+		restored.SystemUser = ""
+
+		siteConfig, err := restored.Renamed(envConfig, domain, displayName)
+		if err != nil {
+			fail(withExitCode(ExitFileStructure, err))
+		}
+		siteConfig.Tls = restored.Tls
+
+		if err = checkSiteUnowned(envConfig, &siteConfig); err != nil {
+			fail(err)
+		}
+
+		// Archived files are never mixed with existing ones, nor with a template
+		if _, err = os.Stat(siteConfig.FilesRoot()); !os.IsNotExist(err) {
+			fail(withExitCode(ExitFileStructure, fmt.Errorf("directory %s already exists", siteConfig.FilesRoot())))
+		}
+
+		undo.add(func() error {
+			return os.RemoveAll(siteConfig.FilesRoot())
+		})
+
+		if err = copyDirs.Copy(path.Join(workDir, structs.ArchiveFilesDir), siteConfig.FilesRoot()); err != nil {
+			fail(withExitCode(ExitFileStructure, fmt.Errorf("could not restore archived files: %w", err)))
+		}
+
+		println(fmt.Sprintf("[%s] Restored archived files to %s", domain, siteConfig.FilesRoot()))
+
+		if viper.GetBool("systemUser.enabled") {
+			if err = createSiteUser(envConfig, &siteConfig, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		if programType.Base() == utils.ProgramTypePhp && viper.GetBool("phpFpm.enabled") {
+			if err = createSitePhpPool(&siteConfig, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		if programType.Base() == utils.ProgramTypeApp {
+			if err = createSiteService(envConfig, &siteConfig, &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		caddyfile, err := ioutil.ReadFile(path.Join(workDir, structs.ArchiveCaddyfileName))
+		if err != nil {
+			fail(withExitCode(ExitTemplateMissing, fmt.Errorf("the archive has no Caddyfile: %w", err)))
+		}
+
+		// Point the Caddyfile to the new location of the files and the new php-fpm pool
+		replacements := []string{archived.FilesRoot, siteConfig.FilesRoot()}
+		if len(archived.PhpSocket) > 0 && len(siteConfig.PhpSocket) > 0 {
+			replacements = append(replacements, archived.PhpSocket, siteConfig.PhpSocket)
+		}
+		caddyfile = []byte(strings.NewReplacer(replacements...).Replace(string(caddyfile)))
+
+		if ok, err := siteConfig.WriteConfig(envConfig, caddyfile); !ok {
+			if os.IsExist(err) {
+				fail(withExitCode(ExitCaddyfileExists, fmt.Errorf("config file for domain %s already exists", domain)))
+			}
+
+			fail(withExitCode(ExitTemplateMissing, err))
+		}
+
+		undo.add(func() error {
+			_, err := siteConfig.RemoveConfig(envConfig)
+			return err
+		})
+
+		println(fmt.Sprintf("[%s] Restored Caddyfile config in %s", domain, siteConfig.Caddyfile()))
+
+		if err = enableSite(envConfig, &siteConfig); err != nil {
+			fail(err)
+		}
+
+		undo.add(func() error {
+			_, err := siteConfig.DisableSite(envConfig)
+			return err
+		})
+
+		if ok, err := siteConfig.ValidateCaddy(envConfig); !ok {
+			fail(withExitCode(ExitCaddyInvalid, err))
+		}
+
+		record := siteConfig.Record()
+
+		if archived.Database != nil && len(manifest.DatabaseDump) > 0 {
+			database, source, err := createSiteDatabase(siteConfig, databaseRequest{
+				Type:  archived.Database.Type,
+				Host:  dbHost,
+				Name:  archived.Database.Name,
+				User:  archived.Database.User,
+				Fresh: true,
+			})
+			if err != nil {
+				fail(err)
+			}
+
+			undo.add(func() error {
+				return dropSiteDatabase(*database)
+			})
+
+			ok, err := restoreFromFile(dumpPath, source.Restore)
+			source.Close()
+			if !ok {
+				fail(withExitCode(ExitDatabaseCreate, fmt.Errorf("there was an error while restoring the database: %w", err)))
+			}
+
+			println(fmt.Sprintf("[%s] Restored database %s from the archive", domain, database.Name))
+
+			record.Database = database
+		}
+
+		if err = registerSite(envConfig, record); err != nil {
+			fail(withExitCode(ExitRegistry, err))
+		}
+
+		undo.add(func() error {
+			return unregisterSite(envConfig, domain)
+		})
+
+		if ok, err := siteConfig.ReloadCaddy(envConfig); !ok {
+			fail(withExitCode(ExitCaddyReload, err))
+		}
+
+		println(fmt.Sprintf("[%s] Website restored from %s", domain, args[0]))
+	},
+}
+
+func extractSiteArchive(archive, destination string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer func(decompressed *gzip.Reader) {
+		_ = decompressed.Close()
+	}(decompressed)
+
+	return utils.ExtractTar(decompressed, destination)
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().StringVarP(&dbAdminUser, "db-admin", "U", "", "Database administrator username")
+	restoreCmd.Flags().StringVarP(&dbAdminPassword, "db-admin-password", "P", "", "Database administrator password")
+	restoreCmd.Flags().StringVarP(&dbHost, "db-host", "H", "127.0.0.1", "Database hostname (with port)")
+	restoreCmd.Flags().StringVarP(&dbAuthDatabase, "db-auth-db", "s", "", "Authentication database (only for mongo)")
+}
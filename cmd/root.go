@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
 	"github.com/spf13/cobra"
 	"os"
 	"path"
@@ -53,6 +55,20 @@ func init() {
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	viper.SetDefault("systemUser.webServerUser", "caddy")
+	viper.SetDefault("phpFpm.socketDirectory", "/run/php")
+	viper.SetDefault("phpFpm.user", "www-data")
+	viper.SetDefault("phpFpm.pm.mode", "dynamic")
+	viper.SetDefault("phpFpm.pm.maxChildren", 5)
+	viper.SetDefault("phpFpm.pm.startServers", 2)
+	viper.SetDefault("phpFpm.pm.minSpareServers", 1)
+	viper.SetDefault("phpFpm.pm.maxSpareServers", 3)
+	viper.SetDefault("ports.range", "9000-9999")
+	viper.SetDefault("systemd.unitDirectory", "/etc/systemd/system")
+	viper.SetDefault("systemd.user", "www-data")
+	viper.SetDefault("systemd.restart", "on-failure")
+	viper.SetDefault("systemd.systemctl", "systemctl")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -76,6 +92,23 @@ func initConfig() {
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+
+		// Website types declared in addition to the built-in ones
+		var types []utils.ProgramTypeDefinition
+		cobra.CheckErr(viper.UnmarshalKey("types", &types))
+		cobra.CheckErr(utils.RegisterProgramTypes(types))
+
+		// Hardening presets declared in addition to (or replacing) the built-in ones
+		var presets []structs.HardeningPreset
+		cobra.CheckErr(viper.UnmarshalKey("presets", &presets))
+		cobra.CheckErr(structs.RegisterHardeningPresets(presets))
+
+		// Newer Public Suffix List may be provided without rebuilding the binary
+		if pslFile := viper.GetString("publicSuffixList"); len(pslFile) > 0 {
+			if err := utils.LoadPublicSuffixList(pslFile); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not load public suffix list, using the embedded one:", err)
+			}
+		}
 	} else if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 		sampleViper := viper.New()
 
@@ -100,9 +133,89 @@ func initConfig() {
 			"authDatabase": "",
 		})
 
+		sampleViper.Set("backups", map[string]interface{}{
+			"directory": "",
+			"retention": 7,
+		})
+
+		sampleViper.Set("publicSuffixList", "")
+
+		sampleViper.Set("systemUser", map[string]interface{}{
+			"enabled":       false,
+			"webServerUser": "caddy",
+		})
+
+		sampleViper.Set("phpFpm", map[string]interface{}{
+			"enabled":         false,
+			"poolDirectory":   "/etc/php/8.0/fpm/pool.d",
+			"socketDirectory": "/run/php",
+			"user":            "www-data",
+			"reloadCommand":   "systemctl reload php8.0-fpm",
+			"pm": map[string]interface{}{
+				"mode":            "dynamic",
+				"maxChildren":     5,
+				"startServers":    2,
+				"minSpareServers": 1,
+				"maxSpareServers": 3,
+			},
+		})
+
+		sampleViper.Set("types", []map[string]interface{}{
+			{
+				"name":              "node",
+				"description":       "Node.js server-side rendering",
+				"base":              "application",
+				"aliases":           []string{"ssr"},
+				"caddyfileTemplate": "template_node",
+				"filesTemplate":     "node",
+				"requiredVars":      []string{"start_command"},
+				"port":              3000,
+				"vars":              map[string]string{"node_env": "production"},
+			},
+		})
+
+		sampleViper.Set("ports", map[string]string{
+			"range": "9000-9999",
+		})
+
+		sampleViper.Set("systemd", map[string]interface{}{
+			"unitDirectory": "/etc/systemd/system",
+			"user":          "www-data",
+			"restart":       "on-failure",
+			"systemctl":     "systemctl",
+			"enable":        false,
+			"start":         false,
+		})
+
+		sampleViper.Set("presets", []map[string]interface{}{
+			{
+				"name":          "intranet",
+				"description":   "Internal tools, kept out of search engines",
+				"headers":       []string{"X-Robots-Tag: noindex, nofollow", "X-Frame-Options: DENY"},
+				"removeHeaders": []string{"Server"},
+				"block":         []string{"*/.*", "*/database_info.txt"},
+			},
+		})
+
+		sampleViper.Set("tls", map[string]string{
+			"email": "",
+			"ca":    "",
+		})
+
+		sampleViper.Set("layout", map[string]string{
+			"type":    "nested",
+			"pattern": "",
+		})
+
 		err = sampleViper.SafeWriteConfig()
 		if err == nil {
 			println(fmt.Sprintf("Created sample config file at %s. You can fill it and rename to .cdm.yaml to make it work.", path.Join(home, ".cdm.sample.yaml")))
 		}
 	}
+
+	// Directory layout of site files: nested (default), flat or pattern
+	layout, err := structs.NewDirectoryLayout(viper.GetString("layout.type"), viper.GetString("layout.pattern"))
+	cobra.CheckErr(err)
+
+	structs.DefaultLayout = layout
 }
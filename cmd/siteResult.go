@@ -0,0 +1,79 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"os"
+)
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+// siteResult is the machine-readable outcome of creating a site, printed with --output json.
+type siteResult struct {
+	Domain    string                  `json:"domain"`
+	Type      utils.ProgramType       `json:"type,omitempty"`
+	Port      int                     `json:"port,omitempty"`
+	FilesRoot string                  `json:"filesRoot,omitempty"`
+	Caddyfile string                  `json:"caddyfile,omitempty"`
+	Database  *structs.DatabaseRecord `json:"database,omitempty"`
+	BasicAuth *siteResultBasicAuth    `json:"basicAuth,omitempty"`
+	Reloaded  bool                    `json:"reloaded"`
+	Error     string                  `json:"error,omitempty"`
+	ExitCode  int                     `json:"exitCode"`
+}
+
+// siteResultBasicAuth holds the generated basic auth password, which is not stored anywhere else.
+type siteResultBasicAuth struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+func newSiteResult(domain string, record structs.SiteRecord, err error) siteResult {
+	result := siteResult{
+		Domain:    domain,
+		Type:      record.Type,
+		Port:      record.Port,
+		FilesRoot: record.FilesRoot,
+		Caddyfile: record.Caddyfile,
+		Database:  record.Database,
+		ExitCode:  exitCode(err),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// printJson writes the value to stdout, as progress messages go to stderr.
+func printJson(value interface{}) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		println(fmt.Sprintf("Could not encode the result: %s", err.Error()))
+		os.Exit(ExitGeneric)
+	}
+
+	fmt.Println(string(content))
+}
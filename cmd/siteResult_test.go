@@ -0,0 +1,71 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"reflect"
+	"testing"
+)
+
+func TestNewSiteResult(t *testing.T) {
+	database := &structs.DatabaseRecord{Type: utils.DatabaseMysql, Name: "example_com", User: "example_com"}
+	record := structs.SiteRecord{
+		DomainName: "example.com",
+		Type:       utils.ProgramTypePhp,
+		Caddyfile:  "/etc/caddy/sites-all/example.com.Caddyfile",
+		FilesRoot:  "/var/www/example.com",
+		Database:   database,
+	}
+
+	tables := []struct {
+		record   structs.SiteRecord
+		err      error
+		expected siteResult
+	}{
+		{record, nil, siteResult{
+			Domain:    "example.com",
+			Type:      utils.ProgramTypePhp,
+			FilesRoot: "/var/www/example.com",
+			Caddyfile: "/etc/caddy/sites-all/example.com.Caddyfile",
+			Database:  database,
+			ExitCode:  ExitOk,
+		}},
+		{record, withExitCode(ExitDatabaseUser, errors.New("user failed")), siteResult{
+			Domain:    "example.com",
+			Type:      utils.ProgramTypePhp,
+			FilesRoot: "/var/www/example.com",
+			Caddyfile: "/etc/caddy/sites-all/example.com.Caddyfile",
+			Database:  database,
+			Error:     "user failed",
+			ExitCode:  ExitDatabaseUser,
+		}},
+		{structs.SiteRecord{}, withExitCode(ExitCaddyfileExists, errors.New("exists")), siteResult{
+			Domain:   "example.com",
+			Error:    "exists",
+			ExitCode: ExitCaddyfileExists,
+		}},
+	}
+
+	for _, table := range tables {
+		if result := newSiteResult("example.com", table.record, table.err); !reflect.DeepEqual(result, table.expected) {
+			t.Errorf("Site result incorrect, expected %+v, got %+v", table.expected, result)
+		}
+	}
+}
@@ -0,0 +1,1038 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/databases"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/viper"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Steps of creating a website, shared by createSite and the commands recreating existing sites.
+
+// databaseRequest describes the database and user to create for a website.
+type databaseRequest struct {
+	Type     utils.DatabaseType
+	Host     string // host:port
+	Name     string
+	User     string
+	Password string
+	UserHost string
+	Fresh    bool // Refuse an existing database or user, instead of reusing them
+}
+
+// domainArg normalizes the domain given as an argument, exiting if it is not valid.
+func domainArg(arg string) string {
+	domain, _, err := utils.NormalizeDomain(arg)
+	if err != nil {
+		println(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	return domain
+}
+
+// normalizeAliases validates alias domains, dropping duplicates and the site's own domain.
+func normalizeAliases(domain string, aliases []string) ([]string, error) {
+	var normalized []string
+	seen := map[string]bool{domain: true}
+
+	for _, alias := range aliases {
+		ascii, _, err := utils.NormalizeDomain(alias)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %w", alias, err)
+		}
+
+		if !seen[ascii] {
+			seen[ascii] = true
+			normalized = append(normalized, ascii)
+		}
+	}
+
+	return normalized, nil
+}
+
+// parseTemplateVars reads --var name=value flags.
+func parseTemplateVars(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	vars := map[string]string{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, fmt.Errorf("variable %q should look like name=value", value)
+		}
+
+		vars[strings.TrimSpace(parts[0])] = parts[1]
+	}
+
+	return vars, nil
+}
+
+// normalizeUpstreams validates upstreams and load balancing options of proxy sites.
+func normalizeUpstreams(siteConfig *structs.SiteConfig, upstreams []string, policy, healthPath, healthInterval string) error {
+	for _, upstream := range upstreams {
+		normalized, err := utils.NormalizeUpstream(upstream)
+		if err != nil {
+			return err
+		}
+
+		siteConfig.Upstreams = append(siteConfig.Upstreams, normalized)
+	}
+
+	if !utils.ValidLbPolicy(policy) {
+		return fmt.Errorf("%s is not correct load balancing policy. Please, use one of Caddy's lb_policy values, i.e. 'round_robin' or 'least_conn'", policy)
+	}
+
+	if !utils.ValidHealthInterval(healthInterval) {
+		return fmt.Errorf("%s is not correct health check interval. Please, use a duration like '10s'", healthInterval)
+	}
+
+	if len(healthPath) > 0 && !strings.HasPrefix(healthPath, "/") {
+		return fmt.Errorf("health check path %s should start with /", healthPath)
+	}
+
+	siteConfig.LbPolicy, siteConfig.HealthPath, siteConfig.HealthEvery = policy, healthPath, healthInterval
+
+	return nil
+}
+
+// provisionSite runs all steps of createSite for the site, except reloading Caddy. Steps up to enabling the site
+// are undone if one of them fails. The site is registered as soon as it is enabled, so a failing database does not hide it.
+func provisionSite(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig, database *databaseRequest) (structs.SiteRecord, error) {
+	var undo rollback
+
+	fail := func(err error) (structs.SiteRecord, error) {
+		undo.run(siteConfig.DomainName)
+		return structs.SiteRecord{}, err
+	}
+
+	if err := checkSiteUnowned(envConfig, siteConfig); err != nil {
+		return fail(err)
+	}
+
+	if err := createSiteStructure(envConfig, siteConfig); err != nil {
+		return fail(err)
+	}
+
+	if viper.GetBool("systemUser.enabled") {
+		if err := createSiteUser(envConfig, siteConfig, &undo); err != nil {
+			return fail(err)
+		}
+	}
+
+	if siteConfig.Type.Base() == utils.ProgramTypePhp && viper.GetBool("phpFpm.enabled") {
+		if err := createSitePhpPool(siteConfig, &undo); err != nil {
+			return fail(err)
+		}
+	}
+
+	if siteConfig.Type.Base() == utils.ProgramTypeApp {
+		if err := createSiteService(envConfig, siteConfig, &undo); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := createSiteCaddyfile(envConfig, siteConfig); err != nil {
+		return fail(err)
+	}
+
+	undo.add(func() error {
+		_, err := siteConfig.RemoveConfig(envConfig)
+		return err
+	})
+
+	if err := enableSite(envConfig, siteConfig); err != nil {
+		return fail(err)
+	}
+
+	record := siteConfig.Record()
+	registerSite(envConfig, record)
+
+	if database == nil {
+		return record, nil
+	}
+
+	databaseRecord, source, err := createSiteDatabase(*siteConfig, *database)
+	if err != nil {
+		return record, err
+	}
+	source.Close()
+
+	record.Database = databaseRecord
+	registerSite(envConfig, record)
+
+	return record, nil
+}
+
+func createSiteStructure(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig) error {
+	if ok, err := siteConfig.CreateFileStructure(envConfig); !ok {
+		if os.IsNotExist(err) {
+			println(fmt.Sprintf("Warning: template directory for %s type does not exist; omitting file structure copy.", strings.ToLower(string(siteConfig.Type))))
+		} else if err.Error() == "domain directory not empty" {
+			println(fmt.Sprintf("Warning: directory structure for %s already exists and is not empty; omitting file structure copy.", siteConfig.DomainName))
+		} else {
+			return withExitCode(ExitFileStructure, err)
+		}
+	}
+
+	println(fmt.Sprintf("[%s] Created file structure in %s using %s template", siteConfig.DomainName, siteConfig.FilesRoot(), strings.ToLower(string(siteConfig.Type))))
+
+	return nil
+}
+
+// createSiteUser gives the site its own system user and group, owning the files root.
+// The web server user joins the group, so it can read (but not write) the files. Files roots of sites nested
+// inside stay with their own users, and so do files of a site around it, which only opens the way down.
+func createSiteUser(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig, undo *rollback) error {
+	name := utils.SystemUserName(siteConfig.DomainName)
+
+	registryMutex.Lock()
+	registry, err := structs.LoadRegistry(envConfig)
+	registryMutex.Unlock()
+	if err != nil {
+		return withExitCode(ExitRegistry, fmt.Errorf("could not read the sites registry (%s): %w", envConfig.Registry, err))
+	}
+
+	var nested []string
+	for _, domain := range registry.SitesInside(siteConfig.FilesRoot()) {
+		nested = append(nested, registry.Sites[domain].FilesRoot)
+	}
+
+	if around, ok := registry.SiteAround(siteConfig.FilesRoot()); ok && len(registry.Sites[around].SystemUser) > 0 {
+		if err = utils.OpenTraversal(registry.Sites[around].FilesRoot, siteConfig.FilesRoot()); err != nil {
+			return withExitCode(ExitSystemUser, fmt.Errorf("could not open %s for %s: %w", registry.Sites[around].FilesRoot, name, err))
+		}
+	}
+
+	uid, gid, err := utils.CreateSystemUser(utils.DefaultSystemUsers, name, siteConfig.FilesRoot(), viper.GetString("systemUser.webServerUser"))
+	if err != nil {
+		return withExitCode(ExitSystemUser, fmt.Errorf("could not create system user %s: %w", name, err))
+	}
+
+	undo.add(func() error {
+		return utils.DefaultSystemUsers.DeleteUser(name)
+	})
+
+	if err = utils.SecureTree(utils.DefaultSystemUsers, siteConfig.FilesRoot(), uid, gid, nested); err != nil {
+		return withExitCode(ExitSystemUser, fmt.Errorf("could not hand %s over to %s: %w", siteConfig.FilesRoot(), name, err))
+	}
+
+	siteConfig.SystemUser = name
+
+	println(fmt.Sprintf("[%s] Files root owned by system user %s", siteConfig.DomainName, name))
+
+	return nil
+}
+
+// secureSitePaths hands paths added to the files root over to the site's system user, if it has one.
+func secureSitePaths(siteConfig structs.SiteConfig, paths []string) error {
+	if len(siteConfig.SystemUser) == 0 {
+		return nil
+	}
+
+	uid, gid, err := utils.DefaultSystemUsers.Lookup(siteConfig.SystemUser)
+	if err != nil {
+		return withExitCode(ExitSystemUser, fmt.Errorf("system user %s: %w", siteConfig.SystemUser, err))
+	}
+
+	for _, sitePath := range paths {
+		if err = utils.SecureTree(utils.DefaultSystemUsers, sitePath, uid, gid, nil); err != nil {
+			return withExitCode(ExitSystemUser, err)
+		}
+	}
+
+	return nil
+}
+
+// writeDatabaseInfo stores credentials of the database in the files root, owned by the site's system user if it has one.
+// Failing to do so only warns, as the database itself is already usable.
+func writeDatabaseInfo(siteConfig structs.SiteConfig, database structs.DatabaseRecord) {
+	_, err := siteConfig.WriteDatabaseInfo(database.Host, database.Port, database.Name, database.User, database.Password, database.UserHost)
+	if err == nil {
+		err = secureSitePaths(siteConfig, []string{siteConfig.DatabaseInfoPath()})
+	}
+
+	if err != nil {
+		println(fmt.Sprintf("Warning: [%s] could not store database credentials in %s: %s", siteConfig.DomainName, siteConfig.DatabaseInfoPath(), err.Error()))
+	}
+}
+
+// phpPoolSettings reads the phpFpm section of the config.
+func phpPoolSettings() structs.PhpPoolSettings {
+	return structs.PhpPoolSettings{
+		PoolDirectory:   viper.GetString("phpFpm.poolDirectory"),
+		SocketDirectory: viper.GetString("phpFpm.socketDirectory"),
+		User:            viper.GetString("phpFpm.user"),
+		ListenOwner:     viper.GetString("systemUser.webServerUser"),
+		ReloadCommand:   viper.GetString("phpFpm.reloadCommand"),
+		Pm:              viper.GetString("phpFpm.pm.mode"),
+		MaxChildren:     viper.GetInt("phpFpm.pm.maxChildren"),
+		StartServers:    viper.GetInt("phpFpm.pm.startServers"),
+		MinSpareServers: viper.GetInt("phpFpm.pm.minSpareServers"),
+		MaxSpareServers: viper.GetInt("phpFpm.pm.maxSpareServers"),
+	}
+}
+
+// createSitePhpPool gives the PHP site its own php-fpm pool, available as $PHP_SOCKET in the Caddyfile template.
+// Removing the pool is added to undo.
+func createSitePhpPool(siteConfig *structs.SiteConfig, undo *rollback) error {
+	settings := phpPoolSettings()
+
+	if ok, err := siteConfig.CreatePhpPool(settings); !ok {
+		if os.IsExist(err) {
+			return withExitCode(ExitPhpPool, fmt.Errorf("php-fpm pool %s already exists", siteConfig.PhpPoolName()))
+		}
+
+		return withExitCode(ExitPhpPool, fmt.Errorf("could not create php-fpm pool: %w", err))
+	}
+
+	pooled := *siteConfig
+	undo.add(func() error {
+		if _, err := pooled.RemovePhpPool(settings); err != nil {
+			return err
+		}
+
+		_, err := structs.ReloadPhpFpm(settings)
+		return err
+	})
+
+	println(fmt.Sprintf("[%s] Created php-fpm pool listening on %s", siteConfig.DomainName, siteConfig.PhpSocket))
+
+	if reloaded, err := structs.ReloadPhpFpm(settings); err != nil {
+		return withExitCode(ExitPhpPool, fmt.Errorf("could not reload php-fpm: %w", err))
+	} else if !reloaded {
+		println(fmt.Sprintf("Warning: [%s] phpFpm.reloadCommand is not set, reload php-fpm by hand so it listens on %s", siteConfig.DomainName, siteConfig.PhpSocket))
+	}
+
+	return nil
+}
+
+// systemdSettings reads the systemd section of the config.
+func systemdSettings() structs.SystemdSettings {
+	return structs.SystemdSettings{
+		UnitDirectory: viper.GetString("systemd.unitDirectory"),
+		User:          viper.GetString("systemd.user"),
+		Restart:       viper.GetString("systemd.restart"),
+	}
+}
+
+// createSiteService installs a systemd unit running the application, if its template has one.
+// The unit is enabled and started only when asked to. Stopping, disabling and removing the unit is added to undo.
+func createSiteService(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig, undo *rollback) error {
+	services := structs.SystemctlRunner{Command: viper.GetString("systemd.systemctl")}
+	settings := systemdSettings()
+
+	if ok, err := siteConfig.CreateSystemdUnit(envConfig, settings, services); !ok {
+		if os.IsNotExist(err) {
+			println(fmt.Sprintf("Warning: template directory for %s type has no %s; omitting systemd unit.", strings.ToLower(string(siteConfig.Type)), structs.SystemdUnitTemplateName))
+			return nil
+		} else if os.IsExist(err) {
+			return withExitCode(ExitService, fmt.Errorf("systemd unit %s already exists", siteConfig.ServiceName()))
+		}
+
+		return withExitCode(ExitService, fmt.Errorf("could not install systemd unit: %w", err))
+	}
+
+	installed := *siteConfig
+	undo.add(func() error {
+		_, err := installed.RemoveSystemdUnit(settings, services)
+		return err
+	})
+
+	println(fmt.Sprintf("[%s] Installed systemd unit %s", siteConfig.DomainName, siteConfig.Service))
+
+	if viper.GetBool("systemd.enable") {
+		if err := services.Enable(siteConfig.Service); err != nil {
+			return withExitCode(ExitService, err)
+		}
+
+		undo.add(func() error {
+			return services.Disable(installed.Service)
+		})
+	}
+
+	if viper.GetBool("systemd.start") {
+		if err := services.Start(siteConfig.Service); err != nil {
+			return withExitCode(ExitService, err)
+		}
+
+		undo.add(func() error {
+			return services.Stop(installed.Service)
+		})
+
+		println(fmt.Sprintf("[%s] Started %s", siteConfig.DomainName, siteConfig.Service))
+	}
+
+	return nil
+}
+
+// switchSiteRuntime creates the php-fpm pool or systemd unit the site's (new) type needs and does not have yet,
+// adding their removal to undo. A site which stops being a PHP one loses its socket, the returned previous config
+// lets removeReplacedPhpPool remove the pool once the new Caddyfile is in place.
+func switchSiteRuntime(envConfig utils.EnvironmentConfig, site *structs.SiteConfig, undo *rollback) (structs.SiteConfig, error) {
+	previous := *site
+	isPhp := site.Type.Base() == utils.ProgramTypePhp
+	isApp := site.Type.Base() == utils.ProgramTypeApp
+
+	if isPhp && len(site.PhpSocket) == 0 && viper.GetBool("phpFpm.enabled") {
+		if err := createSitePhpPool(site, undo); err != nil {
+			return previous, err
+		}
+	} else if !isPhp {
+		site.PhpSocket = ""
+	}
+
+	if isApp && len(site.Service) == 0 {
+		if err := createSiteService(envConfig, site, undo); err != nil {
+			return previous, err
+		}
+	} else if !isApp && len(site.Service) > 0 {
+		println(fmt.Sprintf("Warning: [%s] systemd unit %s is left in place, stop and remove it when the application is gone", site.DomainName, site.Service))
+	}
+
+	return previous, nil
+}
+
+// removeReplacedPhpPool removes the pool of a site which stopped being a PHP one.
+// Failing to do so only warns, as the site already works without it.
+func removeReplacedPhpPool(previous, site structs.SiteConfig) {
+	if len(previous.PhpSocket) == 0 || len(site.PhpSocket) > 0 {
+		return
+	}
+
+	_, err := previous.RemovePhpPool(phpPoolSettings())
+	if err == nil {
+		_, err = structs.ReloadPhpFpm(phpPoolSettings())
+	}
+
+	if err != nil {
+		println(fmt.Sprintf("Warning: [%s] could not remove php-fpm pool: %s", site.DomainName, err.Error()))
+	} else {
+		println(fmt.Sprintf("[%s] Removed php-fpm pool", site.DomainName))
+	}
+}
+
+// updateSiteService renders the installed unit of the site again and restarts the service if it is running,
+// so it picks up i.e. a new port. Putting the previous unit back is added to undo.
+func updateSiteService(envConfig utils.EnvironmentConfig, site, previous structs.SiteConfig, undo *rollback) error {
+	services := structs.SystemctlRunner{Command: viper.GetString("systemd.systemctl")}
+	settings := systemdSettings()
+
+	changed, err := site.UpdateSystemdUnit(envConfig, settings, services)
+	if err != nil {
+		return withExitCode(ExitService, fmt.Errorf("could not update systemd unit %s: %w", site.Service, err))
+	} else if !changed {
+		return nil
+	}
+
+	undo.add(func() error {
+		if _, err := previous.UpdateSystemdUnit(envConfig, settings, services); err != nil {
+			return err
+		}
+
+		return services.TryRestart(previous.Service)
+	})
+
+	if err = services.TryRestart(site.Service); err != nil {
+		return withExitCode(ExitService, err)
+	}
+
+	println(fmt.Sprintf("[%s] Updated systemd unit %s, restarted the service if it was running", site.DomainName, site.Service))
+
+	return nil
+}
+
+// removeSiteService stops, disables and removes the systemd unit of the site, if it has one.
+func removeSiteService(site *structs.SiteConfig) error {
+	if len(site.Service) == 0 {
+		return nil
+	}
+
+	services := structs.SystemctlRunner{Command: viper.GetString("systemd.systemctl")}
+	service := site.Service
+
+	if err := services.Stop(service); err != nil {
+		return withExitCode(ExitService, err)
+	}
+
+	if err := services.Disable(service); err != nil {
+		return withExitCode(ExitService, err)
+	}
+
+	if _, err := site.RemoveSystemdUnit(systemdSettings(), services); err != nil {
+		return withExitCode(ExitService, err)
+	}
+
+	println(fmt.Sprintf("[%s] Stopped and removed systemd unit %s", site.DomainName, service))
+
+	return nil
+}
+
+func createSiteCaddyfile(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig) error {
+	if ok, err := siteConfig.CreateConfig(envConfig); !ok {
+		if os.IsExist(err) {
+			return withExitCode(ExitCaddyfileExists, fmt.Errorf("config file for domain %s already exists", siteConfig.DomainName))
+		} else if os.IsNotExist(err) {
+			return withExitCode(ExitTemplateMissing, fmt.Errorf("template file for type %s do not exist", strings.ToLower(string(siteConfig.Type))))
+		} else {
+			return err
+		}
+	}
+
+	println(fmt.Sprintf("[%s] Created Caddyfile config in %s using %s template", siteConfig.DomainName, siteConfig.Caddyfile(), strings.ToLower(string(siteConfig.Type))))
+
+	return nil
+}
+
+func enableSite(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig) error {
+	if ok, err := siteConfig.EnableSite(envConfig); !ok {
+		if os.IsNotExist(err) {
+			return withExitCode(ExitEnableSite, fmt.Errorf("caddyfile for domain %s do not exist", siteConfig.DomainName))
+		} else {
+			return withExitCode(ExitEnableSite, err)
+		}
+	}
+
+	println(fmt.Sprintf("[%s] Created symlink for Caddyfile in sites-enabled directory", siteConfig.DomainName))
+
+	return nil
+}
+
+// fillDatabaseDefaults completes the request with configured or derived values.
+func fillDatabaseDefaults(siteConfig structs.SiteConfig, request *databaseRequest) {
+	keysPrefix := strings.ToLower(string(request.Type)) + "."
+
+	if len(request.Host) == 0 || request.Host == "127.0.0.1" {
+		conf := viper.GetString(keysPrefix + "host")
+		if len(conf) > 0 && request.Host != "127.0.0.1" {
+			request.Host = conf
+		} else {
+			var port int
+
+			switch request.Type {
+			case utils.DatabaseMongo:
+				port = 27017
+				break
+			case utils.DatabaseMysql:
+				port = 3306
+				break
+			}
+
+			request.Host = fmt.Sprintf("127.0.0.1:%d", port)
+		}
+	}
+
+	if len(request.User) == 0 {
+		request.User = siteConfig.UserName()
+	}
+
+	if len(request.Name) == 0 {
+		request.Name = request.User
+	}
+
+	if len(request.Password) == 0 {
+		request.Password = utils.RandomPassword(16)
+	}
+
+	if len(request.UserHost) == 0 {
+		switch request.Type {
+		case utils.DatabaseMysql:
+			request.UserHost = "localhost"
+			break
+		case utils.DatabaseMongo:
+			request.UserHost = "127.0.0.1"
+			break
+		}
+	}
+}
+
+// createSiteDatabase creates the database and its user, storing connection details in the website's root.
+// On success, the returned source is still connected to the new database and has to be closed by the caller.
+func createSiteDatabase(siteConfig structs.SiteConfig, request databaseRequest) (*structs.DatabaseRecord, databases.DatabaseSource, error) {
+	if err := resolveDatabaseAdmin(request.Type); err != nil {
+		return nil, nil, withExitCode(ExitDatabaseConfig, err)
+	}
+
+	fillDatabaseDefaults(siteConfig, &request)
+
+	splitted := strings.Split(request.Host, ":")
+
+	if len(splitted) != 2 {
+		return nil, nil, withExitCode(ExitDatabaseConfig, fmt.Errorf("the host (%s) is in incorrect format - it should be host:port", request.Host))
+	}
+
+	host := splitted[0]
+	port, err := strconv.Atoi(splitted[1])
+
+	if err != nil {
+		return nil, nil, withExitCode(ExitDatabaseConfig, fmt.Errorf("the host (%s) has incorrect port: %w", request.Host, err))
+	}
+
+	// Try to create database
+	source := newDatabaseSource(request.Type, host, port)
+
+	if ok := source.Connect(); !ok {
+		return nil, nil, withExitCode(ExitDatabaseConnect, errors.New("there was an error while connecting to the database server"))
+	}
+
+	if request.Fresh && source.UseDatabase(request.Name) {
+		source.Close()
+		return nil, nil, withExitCode(ExitDatabaseCreate, fmt.Errorf("database %s already exists", request.Name))
+	}
+
+	if ok := source.CreateDatabase(request.Name); !ok {
+		source.Close()
+		return nil, nil, withExitCode(ExitDatabaseCreate, errors.New("there was an error while creating the database"))
+	}
+
+	if request.Fresh && source.UserExists(request.User, request.UserHost) {
+		source.DropDatabase(request.Name)
+		source.Close()
+		return nil, nil, withExitCode(ExitDatabaseUser, fmt.Errorf("database user %s already exists", request.User))
+	}
+
+	println(fmt.Sprintf("[%s] Created database %s in %s server %s:%d", siteConfig.DomainName, request.Name, strings.ToLower(string(request.Type)), host, port))
+
+	if ok := source.CreateUser(request.User, request.UserHost, request.Password); !ok {
+		source.Close()
+		return nil, nil, withExitCode(ExitDatabaseUser, errors.New("there was an error while creating the database user"))
+	}
+
+	record := &structs.DatabaseRecord{
+		Type:     request.Type,
+		Host:     host,
+		Port:     port,
+		Name:     request.Name,
+		User:     request.User,
+		Password: request.Password,
+		UserHost: request.UserHost,
+	}
+
+	writeDatabaseInfo(siteConfig, *record)
+
+	println(fmt.Sprintf("[%s] Created user %s (with connection limited to %s) and granted privileges on %s in %s server %s:%d. All required information were stored in database_info.txt file in website's root directory", siteConfig.DomainName, request.User, request.UserHost, request.Name, strings.ToLower(string(request.Type)), host, port))
+
+	return record, source, nil
+}
+
+// copySiteDatabase copies the database into a new one named after the site, owned by a new user with given password.
+// Both must not exist yet. Nothing is left behind if copying fails.
+func copySiteDatabase(siteConfig structs.SiteConfig, database structs.DatabaseRecord, password string) (*structs.DatabaseRecord, error) {
+	if err := resolveDatabaseAdmin(database.Type); err != nil {
+		return nil, withExitCode(ExitDatabaseConfig, err)
+	}
+
+	copied := database
+	copied.User = siteConfig.UserName()
+	copied.Name = copied.User
+	copied.Password = password
+
+	if copied.Name == database.Name || copied.User == database.User {
+		return nil, withExitCode(ExitDatabaseCreate, fmt.Errorf("database or user %s already belongs to the website", copied.Name))
+	}
+
+	from := newDatabaseSource(database.Type, database.Host, database.Port)
+	to := newDatabaseSource(database.Type, database.Host, database.Port)
+
+	if ok := from.Connect(); !ok {
+		return nil, withExitCode(ExitDatabaseConnect, errors.New("there was an error while connecting to the database server"))
+	}
+	defer from.Close()
+
+	if ok := to.Connect(); !ok {
+		return nil, withExitCode(ExitDatabaseConnect, errors.New("there was an error while connecting to the database server"))
+	}
+	defer to.Close()
+
+	if ok := from.UseDatabase(database.Name); !ok {
+		return nil, withExitCode(ExitDatabaseCreate, fmt.Errorf("database %s does not exist", database.Name))
+	}
+
+	// Existing objects, i.e. of a site removed without its database, are neither reused nor dropped on failure
+	if to.UseDatabase(copied.Name) {
+		return nil, withExitCode(ExitDatabaseCreate, fmt.Errorf("database %s already exists", copied.Name))
+	}
+
+	if ok := to.CreateDatabase(copied.Name); !ok {
+		return nil, withExitCode(ExitDatabaseCreate, errors.New("there was an error while creating the database"))
+	}
+
+	if to.UserExists(copied.User, copied.UserHost) {
+		to.DropDatabase(copied.Name)
+		return nil, withExitCode(ExitDatabaseUser, fmt.Errorf("database user %s already exists", copied.User))
+	}
+
+	if ok := to.CreateUser(copied.User, copied.UserHost, copied.Password); !ok {
+		to.DropDatabase(copied.Name)
+		return nil, withExitCode(ExitDatabaseUser, errors.New("there was an error while creating the database user"))
+	}
+
+	// Dump is streamed right into the new database
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := from.Dump(writer)
+		_ = writer.CloseWithError(err)
+	}()
+
+	if ok, err := to.Restore(reader); !ok {
+		_ = reader.CloseWithError(err)
+		to.DropUser(copied.User, copied.UserHost)
+		to.DropDatabase(copied.Name)
+
+		return nil, withExitCode(ExitDatabaseCreate, fmt.Errorf("could not copy database %s: %w", database.Name, err))
+	}
+
+	println(fmt.Sprintf("[%s] Copied database %s to %s, owned by user %s", siteConfig.DomainName, database.Name, copied.Name, copied.User))
+
+	return &copied, nil
+}
+
+// dropSiteDatabase removes the database and its user.
+func dropSiteDatabase(database structs.DatabaseRecord) error {
+	if err := resolveDatabaseAdmin(database.Type); err != nil {
+		return withExitCode(ExitDatabaseConfig, err)
+	}
+
+	source := newDatabaseSource(database.Type, database.Host, database.Port)
+	if ok := source.Connect(); !ok {
+		return withExitCode(ExitDatabaseConnect, errors.New("there was an error while connecting to the database server"))
+	}
+	defer source.Close()
+
+	// Mongo users belong to the database
+	if !source.UseDatabase(database.Name) {
+		return fmt.Errorf("database %s does not exist", database.Name)
+	}
+
+	if !source.DropUser(database.User, database.UserHost) {
+		return fmt.Errorf("could not drop database user %s", database.User)
+	}
+
+	if !source.DropDatabase(database.Name) {
+		return fmt.Errorf("could not drop database %s", database.Name)
+	}
+
+	return nil
+}
+
+// rollback undoes steps of an operation which already succeeded, latest first.
+type rollback []func() error
+
+func (steps *rollback) add(undo func() error) {
+	*steps = append(*steps, undo)
+}
+
+// run undoes all steps. Steps which can't be undone are reported, but do not stop the others.
+func (steps rollback) run(domain string) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := steps[i](); err != nil {
+			println(fmt.Sprintf("Warning: [%s] could not roll back: %s", domain, err.Error()))
+		}
+	}
+
+	if len(steps) > 0 {
+		println(fmt.Sprintf("[%s] Rolled back", domain))
+	}
+}
+
+// rerenderSite renders the site's Caddyfile again and validates the whole Caddy configuration,
+// putting the previous Caddyfile back if it is not valid. On success the site is registered.
+func rerenderSite(envConfig utils.EnvironmentConfig, site *structs.SiteConfig, record structs.SiteRecord) (bool, error) {
+	previous, err := ioutil.ReadFile(record.Caddyfile)
+	if err != nil {
+		return false, withExitCode(ExitTemplateMissing, err)
+	}
+
+	changed, err := site.UpdateConfig(envConfig)
+	if err != nil {
+		return false, withExitCode(ExitTemplateMissing, err)
+	}
+
+	if changed {
+		if ok, err := site.ValidateCaddy(envConfig); !ok {
+			if writeErr := ioutil.WriteFile(record.Caddyfile, previous, 0775); writeErr != nil {
+				println(fmt.Sprintf("Warning: could not restore previous Caddyfile of %s: %s", site.DomainName, writeErr.Error()))
+			}
+
+			return false, withExitCode(ExitCaddyInvalid, err)
+		}
+	}
+
+	updated := site.Record()
+	updated.Database = record.Database
+	registerSite(envConfig, updated)
+
+	return changed, nil
+}
+
+// changeSite applies modify to the registered site, then renders, validates and reloads its config.
+// Errors of modify are usage errors.
+func changeSite(domainArgument string, modify func(registry *structs.SiteRegistry, record structs.SiteRecord, site *structs.SiteConfig) error) {
+	envConfig := utils.EnvironmentConfig{}
+
+	if ok, missing := envConfig.ReadEnvironments(); !ok {
+		println("You are missing a required environment variable ", missing)
+		os.Exit(ExitUsage)
+	}
+
+	domain := domainArg(domainArgument)
+
+	registry := loadRegistry(envConfig)
+
+	record, err := registry.Get(domain)
+	if err != nil {
+		println(fmt.Sprintf("Site %s is not registered", domain))
+		os.Exit(ExitGeneric)
+	}
+
+	site := structs.SiteConfigFromRecord(record)
+
+	if err = modify(registry, record, &site); err != nil {
+		println(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	changed, err := rerenderSite(envConfig, &site, record)
+	if err != nil {
+		println(err.Error())
+		os.Exit(exitCode(err))
+	}
+
+	if !changed {
+		println(fmt.Sprintf("[%s] Caddyfile did not change", domain))
+		return
+	}
+
+	println(fmt.Sprintf("[%s] Updated Caddyfile at %s", domain, site.Caddyfile()))
+
+	if site.IsEnabled(envConfig) {
+		if ok, err := site.ReloadCaddy(envConfig); !ok {
+			println(err.Error())
+			os.Exit(ExitCaddyReload)
+		}
+	}
+}
+
+// basicAuthUser hashes the password of the basic auth user, generating one if it is not given.
+func basicAuthUser(user, password string) (structs.BasicAuthUser, string, error) {
+	if len(password) == 0 {
+		password = utils.RandomPassword(16)
+	}
+
+	authUser, err := structs.NewBasicAuthUser(user, password)
+	if err != nil {
+		return structs.BasicAuthUser{}, "", withExitCode(ExitUsage, err)
+	}
+
+	return authUser, password, nil
+}
+
+// TlsNone leaves certificate handling of a site to its template.
+const TlsNone = "none"
+
+// siteTls builds TLS options of a site, with ACME email and CA taken from the config unless given.
+func siteTls(mode, cert, key, email, ca, dns string) (structs.SiteTls, error) {
+	tls := structs.SiteTls{Mode: strings.ToLower(mode), Cert: cert, Key: key, Email: email, Ca: ca, Dns: dns}
+	if tls.Mode == TlsNone {
+		tls.Mode = ""
+	}
+
+	if tls.Mode == structs.TlsAcme {
+		if len(tls.Email) == 0 {
+			tls.Email = viper.GetString("tls.email")
+		}
+
+		if len(tls.Ca) == 0 {
+			tls.Ca = viper.GetString("tls.ca")
+		}
+	}
+
+	if err := tls.Validate(); err != nil {
+		return structs.SiteTls{}, withExitCode(ExitUsage, err)
+	}
+
+	return tls, nil
+}
+
+// sitePreset checks the hardening preset given for a site. "none" removes the preset.
+func sitePreset(name string) (string, error) {
+	if len(name) == 0 || strings.ToLower(name) == "none" {
+		return "", nil
+	}
+
+	preset, err := structs.GetHardeningPreset(name)
+	if err != nil {
+		return "", withExitCode(ExitUsage, err)
+	}
+
+	return preset.Name, nil
+}
+
+// loadRegistry reads the sites registry, exiting if it can't be read.
+func loadRegistry(envConfig utils.EnvironmentConfig) *structs.SiteRegistry {
+	registry, err := structs.LoadRegistry(envConfig)
+	if err != nil {
+		println(fmt.Sprintf("Could not read the sites registry (%s): %s", envConfig.Registry, err.Error()))
+		os.Exit(ExitRegistry)
+	}
+
+	return registry
+}
+
+// registryMutex guards the registry file, as sites may be created concurrently.
+var registryMutex sync.Mutex
+
+// registerSite stores the site in the registry. Failing to do so is usually not fatal, as the site itself already works,
+// so a warning is printed; the error is returned for callers which can't go on without the record.
+func registerSite(envConfig utils.EnvironmentConfig, record structs.SiteRecord) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry, err := structs.LoadRegistry(envConfig)
+	if err == nil {
+		registry.Put(record)
+		_, err = registry.Save()
+	}
+
+	if err != nil {
+		println(fmt.Sprintf("Warning: could not store %s in the sites registry (%s): %s", record.DomainName, envConfig.Registry, err.Error()))
+	}
+
+	return err
+}
+
+// checkSiteUnowned refuses domains, www counterparts, aliases and redirected domains which are already served by registered sites.
+func checkSiteUnowned(envConfig utils.EnvironmentConfig, siteConfig *structs.SiteConfig) error {
+	registryMutex.Lock()
+	registry, err := structs.LoadRegistry(envConfig)
+	registryMutex.Unlock()
+	if err != nil {
+		return withExitCode(ExitRegistry, fmt.Errorf("could not read the sites registry (%s): %w", envConfig.Registry, err))
+	}
+
+	names := append([]string{siteConfig.DomainName}, siteConfig.Aliases...)
+	if counterpart := siteConfig.WwwCounterpart(); len(counterpart) > 0 {
+		names = append(names, counterpart)
+	}
+
+	for _, name := range append(names, siteConfig.RedirectFrom...) {
+		if owner, ok := registry.Owner(name); ok {
+			return withExitCode(ExitUsage, fmt.Errorf("%s is already served by %s", name, owner))
+		}
+	}
+
+	return nil
+}
+
+// unregisterSite removes the site from the registry.
+func unregisterSite(envConfig utils.EnvironmentConfig, domain string) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry, err := structs.LoadRegistry(envConfig)
+	if err != nil {
+		return err
+	}
+
+	registry.Remove(domain)
+	_, err = registry.Save()
+
+	return err
+}
+
+// PortAuto makes --port pick a free port from the configured range.
+const PortAuto = "auto"
+
+var (
+	portMutex sync.Mutex
+	// allocatedPorts remembers ports handed out in this run, before their Caddyfiles exist.
+	allocatedPorts = map[int]bool{}
+)
+
+// sitePort reads the --port value, allocating a free port for "auto".
+func sitePort(envConfig utils.EnvironmentConfig, value string) (int, error) {
+	if value != PortAuto {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return 0, withExitCode(ExitUsage, fmt.Errorf("%s is not correct port. Please, use a number or 'auto'", value))
+		}
+
+		return port, nil
+	}
+
+	port, err := allocatePort(envConfig)
+	if err != nil {
+		return 0, withExitCode(ExitNoFreePort, err)
+	}
+
+	return port, nil
+}
+
+// allocatePort finds a port of the configured range which is not referenced by any site Caddyfile,
+// not recorded for any registered site and not bound locally.
+func allocatePort(envConfig utils.EnvironmentConfig) (int, error) {
+	portMutex.Lock()
+	defer portMutex.Unlock()
+
+	first, last, err := utils.ParsePortRange(viper.GetString("ports.range"))
+	if err != nil {
+		return 0, err
+	}
+
+	used, err := utils.PortsInCaddyfiles(path.Join(envConfig.CaddySites, "sites-all"))
+	if err != nil {
+		return 0, err
+	}
+
+	registryMutex.Lock()
+	registry, err := structs.LoadRegistry(envConfig)
+	registryMutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, domain := range registry.Domains() {
+		record, _ := registry.Get(domain)
+		used[record.Port] = true
+	}
+
+	for port := range allocatedPorts {
+		used[port] = true
+	}
+
+	port, err := utils.FreePort(first, last, used, utils.PortBound)
+	if err != nil {
+		return 0, err
+	}
+
+	allocatedPorts[port] = true
+
+	return port, nil
+}
@@ -0,0 +1,47 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"strings"
+)
+
+// BasicAuthUser is allowed into a site protected with HTTP basic authentication.
+type BasicAuthUser struct {
+	User string `json:"user"`
+	Hash string `json:"hash"` // bcrypt hash of the password, as Caddy's basicauth expects it
+}
+
+var ErrInvalidBasicAuthUser = errors.New("basic auth user name can only contain letters, digits, '.', '_', '@' and '-'")
+
+// Names go into the Caddyfile unquoted, so anything Caddy could read as syntax is refused
+var basicAuthUserPattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// NewBasicAuthUser hashes the password of the user.
+func NewBasicAuthUser(user, password string) (BasicAuthUser, error) {
+	if !basicAuthUserPattern.MatchString(user) {
+		return BasicAuthUser{}, ErrInvalidBasicAuthUser
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return BasicAuthUser{}, err
+	}
+
+	return BasicAuthUser{User: user, Hash: string(hash)}, nil
+}
+
+// basicAuthDirective renders the basicauth block allowing the users in.
+func basicAuthDirective(users []BasicAuthUser, indent string) string {
+	var block strings.Builder
+
+	_, _ = fmt.Fprintf(&block, "%sbasicauth {\n", indent)
+	for _, user := range users {
+		_, _ = fmt.Fprintf(&block, "%s%s%s %s\n", indent, indent, user.User, user.Hash)
+	}
+	_, _ = fmt.Fprintf(&block, "%s}\n", indent)
+
+	return block.String()
+}
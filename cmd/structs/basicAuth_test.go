@@ -0,0 +1,60 @@
+package structs
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestRenderCaddyfileBasicAuth(t *testing.T) {
+	user, err := NewBasicAuthUser("staging", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte("secret")) != nil {
+		t.Errorf("Password hashed incorrectly, got %s", user.Hash)
+	}
+
+	for _, name := range []string{"", "two words", "user}", "{env.SECRET}", "line\nbreak", "#comment", `quoted"`, "zażółć"} {
+		if _, err = NewBasicAuthUser(name, "secret"); err != ErrInvalidBasicAuthUser {
+			t.Errorf("User name %q should be rejected, got %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"staging", "john.doe@example.com", "ci_bot-2"} {
+		if _, err = NewBasicAuthUser(name, "secret"); err != nil {
+			t.Errorf("User name %q should be accepted, got %v", name, err)
+		}
+	}
+
+	cfg := SiteConfig{DomainName: "example.com", BasicAuth: []BasicAuthUser{{User: "staging", Hash: "$2a$10$hash"}}, RedirectFrom: []string{"old.com"}}
+
+	tables := []struct {
+		template string
+		expected string
+	}{
+		{
+			"# Site\n{{.SiteAddress}} {\n\troot * /srv\n}\n",
+			"# Site\nexample.com {\n\tbasicauth {\n\t\tstaging $2a$10$hash\n\t}\n\troot * /srv\n}\n\nold.com {\n\tredir https://example.com{uri} permanent\n}\n",
+		},
+		{
+			"{{.SiteAddress}} {\n  root * /srv\n}\n",
+			"example.com {\n  basicauth {\n    staging $2a$10$hash\n  }\n  root * /srv\n}\n\nold.com {\n\tredir https://example.com{uri} permanent\n}\n",
+		},
+		{
+			"{{.SiteAddress}} {\n{{range .BasicAuth}}\t# {{.User}}\n{{end}}}\n",
+			"example.com {\n\t# staging\n}\n\nold.com {\n\tredir https://example.com{uri} permanent\n}\n",
+		},
+	}
+
+	for _, table := range tables {
+		result, err := renderCaddyfile("test", table.template, cfg.TemplateData())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != table.expected {
+			t.Errorf("Caddyfile rendered incorrectly, expected %q, got %q", table.expected, string(result))
+		}
+	}
+}
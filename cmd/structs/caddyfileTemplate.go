@@ -0,0 +1,350 @@
+package structs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	WwwNone     = "none"
+	WwwRedirect = "redirect" // www.example.com redirects to example.com
+	WwwAlias    = "alias"    // www.example.com is served like example.com
+)
+
+// Redirect is a domain permanently redirected to the site.
+type Redirect struct {
+	From string
+	To   string
+}
+
+// CaddyfileData is available in Caddyfile templates as {{.Field}}. Older $NAME placeholders are
+// turned into actions reading the same data, with $SITE_ADDRESS containing all served addresses.
+type CaddyfileData struct {
+	Domain      string   // Main domain (ASCII)
+	DisplayName string   // Unicode form of the main domain
+	Addresses   []string // Main domain followed by aliases, all served from the files root
+	Aliases     []string
+	Redirects   []Redirect      // Generated as separate site blocks, unless the template uses .Redirects itself
+	BasicAuth   []BasicAuthUser // Added to the first site block, unless the template uses .BasicAuth itself
+	Tls         SiteTls         // Added to every site block as tls directive, unless the template uses .Tls itself
+	Hardening   HardeningPreset // Added to the first site block and its handle blocks, unless the template uses .Hardening (or the hardening snippet, for the site block)
+	FilesRoot   string
+	Port        int
+	Upstreams   []string // Always at least one, 127.0.0.1:Port for sites without upstreams
+	LbPolicy    string
+	HealthPath  string
+	HealthEvery string
+	PhpSocket   string // Socket of the site's PHP-FPM pool, empty unless pools are generated
+	Type        string
+	Vars        map[string]string
+}
+
+// UpstreamList joins all upstreams as reverse_proxy expects them.
+func (data CaddyfileData) UpstreamList() string {
+	return strings.Join(data.Upstreams, " ")
+}
+
+// SiteAddress joins all addresses as the site block expects them.
+func (data CaddyfileData) SiteAddress() string {
+	return strings.Join(data.Addresses, ", ")
+}
+
+func (cfg SiteConfig) TemplateData() CaddyfileData {
+	data := CaddyfileData{
+		Domain:      cfg.DomainName,
+		DisplayName: cfg.Name(),
+		Addresses:   append([]string{cfg.DomainName}, cfg.Aliases...),
+		Aliases:     cfg.Aliases,
+		FilesRoot:   cfg.filesRoot,
+		Port:        cfg.Port,
+		Upstreams:   cfg.Upstreams,
+		LbPolicy:    cfg.LbPolicy,
+		HealthPath:  cfg.HealthPath,
+		HealthEvery: cfg.HealthEvery,
+		PhpSocket:   cfg.PhpSocket,
+		Type:        strings.ToLower(string(cfg.Type)),
+		Vars:        cfg.Vars,
+		BasicAuth:   cfg.BasicAuth,
+		Tls:         cfg.Tls,
+	}
+
+	if len(cfg.Preset) > 0 {
+		data.Hardening, _ = GetHardeningPreset(cfg.Preset)
+	}
+
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+
+	if len(data.Upstreams) == 0 {
+		data.Upstreams = []string{fmt.Sprintf("127.0.0.1:%d", cfg.Port)}
+	}
+
+	for _, from := range cfg.RedirectFrom {
+		data.Redirects = append(data.Redirects, Redirect{From: from, To: cfg.DomainName})
+	}
+
+	if www := cfg.WwwCounterpart(); len(www) > 0 {
+		switch cfg.Www {
+		case WwwAlias:
+			data.Addresses = append(data.Addresses, www)
+		case WwwRedirect:
+			data.Redirects = append(data.Redirects, Redirect{From: www, To: cfg.DomainName})
+		}
+	}
+
+	return data
+}
+
+// WwwCounterpart returns www.example.com for example.com, and example.com for www.example.com.
+func (cfg SiteConfig) WwwCounterpart() string {
+	if len(cfg.Www) == 0 || cfg.Www == WwwNone {
+		return ""
+	}
+
+	if strings.HasPrefix(cfg.DomainName, "www.") {
+		return strings.TrimPrefix(cfg.DomainName, "www.")
+	}
+
+	return "www." + cfg.DomainName
+}
+
+// renderCaddyfile executes the template with site data, appending blocks for redirects.
+func renderCaddyfile(name, content string, data CaddyfileData) ([]byte, error) {
+	parsed, err := template.New(name).Option("missingkey=zero").Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeCaddyfile(parsed, content, data)
+}
+
+// executeCaddyfile runs the parsed template. Sources are searched for .Redirects, .Tls, .Hardening and .BasicAuth,
+// to know whether the template handles them itself.
+func executeCaddyfile(parsed *template.Template, sources string, data CaddyfileData) ([]byte, error) {
+	var out bytes.Buffer
+	if err := parsed.Execute(&out, data); err != nil {
+		return nil, err
+	}
+
+	rendered := out.Bytes()
+	if !strings.Contains(sources, ".Redirects") {
+		for _, redirect := range data.Redirects {
+			rendered = append(rendered, fmt.Sprintf("\n%s {\n\tredir https://%s{uri} permanent\n}\n", redirect.From, redirect.To)...)
+		}
+	}
+
+	hardening := len(data.Hardening.Name) > 0 && !strings.Contains(sources, ".Hardening")
+
+	// Redirecting addresses need certificates too, while protection only matters where files are served
+	return insertIntoBlocks(rendered, func(block caddyfileBlock) string {
+		var directives string
+
+		if block.Handle {
+			if hardening && block.Site == 0 {
+				directives += data.Hardening.blockDirective(block.Prefix, block.Indent)
+			}
+
+			return directives
+		}
+
+		if len(data.Tls.Mode) > 0 && !strings.Contains(sources, ".Tls") {
+			directives += data.Tls.Directive(block.Indent)
+		}
+
+		if block.Site > 0 {
+			return directives
+		}
+
+		if hardening && !strings.Contains(sources, fmt.Sprintf("template %q", HardeningSnippet)) {
+			directives += data.Hardening.Directives(block.Indent)
+		}
+
+		if len(data.BasicAuth) > 0 && !strings.Contains(sources, ".BasicAuth") {
+			directives += basicAuthDirective(data.BasicAuth, block.Indent)
+		}
+
+		return directives
+	}), nil
+}
+
+// caddyfileBlock is a block directives are inserted into: a site block, or a handle or handle_path block directly inside one.
+type caddyfileBlock struct {
+	Site   int    // Position of the site block among site blocks
+	Handle bool   // A handle or handle_path block of the site
+	Prefix string // Indentation of lines inside the block
+	Indent string // One level of indentation, as used by the site block
+}
+
+// insertIntoBlocks puts directives at the beginning of each site block and each of their handle blocks,
+// indented like the block itself. The global options block and snippets are no site blocks.
+func insertIntoBlocks(rendered []byte, directives func(block caddyfileBlock) string) []byte {
+	lines := bytes.SplitAfter(rendered, []byte("\n"))
+
+	var out bytes.Buffer
+	depth, sites := 0, 0
+	inSite, siteIndent := false, "\t"
+
+	for index, line := range lines {
+		out.Write(line)
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+
+		opens := trimmed[len(trimmed)-1] == '{'
+		opensSite := depth == 0 && opens && len(trimmed) > 1 && trimmed[0] != '('
+		opensHandle := depth == 1 && inSite && opens && (bytes.HasPrefix(trimmed, []byte("handle ")) || bytes.HasPrefix(trimmed, []byte("handle_path ")))
+
+		if depth == 0 {
+			inSite = opensSite
+		}
+		depth += bytes.Count(trimmed, []byte("{")) - bytes.Count(trimmed, []byte("}"))
+
+		if !opensSite && !opensHandle {
+			continue
+		}
+
+		prefix := "\t"
+		if index+1 < len(lines) {
+			next := lines[index+1]
+			if unindented := bytes.TrimLeft(next, " \t"); len(bytes.TrimSpace(next)) > 0 && len(unindented) < len(next) {
+				prefix = string(next[:len(next)-len(unindented)])
+			}
+		}
+
+		var block string
+		if opensSite {
+			siteIndent = prefix
+			block = directives(caddyfileBlock{Site: sites, Prefix: prefix, Indent: prefix})
+			sites++
+		} else {
+			block = directives(caddyfileBlock{Site: sites - 1, Handle: true, Prefix: prefix, Indent: siteIndent})
+		}
+
+		if len(block) > 0 && !bytes.HasSuffix(line, []byte("\n")) {
+			block = "\n" + block
+		}
+		out.WriteString(block)
+	}
+
+	return out.Bytes()
+}
+
+// CaddyfileSnippetsDir inside sites-all holds named snippets (file name without extension),
+// which templates include as {{template "security" .}}. The hardening snippet is built in.
+const CaddyfileSnippetsDir = "snippets"
+
+// A template starting with "# extends: template_php" renders its base template,
+// with {{block}}s of the base replaced by its own {{define}}s.
+var extendsRegex = regexp.MustCompile(`^\s*#\s*extends:\s*(\S+)`)
+
+const maxTemplateDepth = 10
+
+type caddyfileSource struct {
+	name    string
+	content string
+}
+
+// caddyfileSources reads the template followed by its bases, i.e. template_laravel, template_php.
+func caddyfileSources(sitesAllPath, name string) ([]caddyfileSource, error) {
+	var sources []caddyfileSource
+	seen := map[string]bool{}
+
+	for len(name) > 0 {
+		if seen[name] || len(sources) == maxTemplateDepth {
+			return nil, fmt.Errorf("bases of template %s are cyclic or nested too deep", sources[0].name)
+		}
+		seen[name] = true
+
+		if strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("template %s extends %s, which is not a template name", sources[len(sources)-1].name, name)
+		}
+
+		content, err := ioutil.ReadFile(path.Join(sitesAllPath, name))
+		if err != nil {
+			if len(sources) > 0 && os.IsNotExist(err) {
+				return nil, fmt.Errorf("template %s extends %s, which does not exist", sources[len(sources)-1].name, name)
+			}
+
+			return nil, err
+		}
+
+		sources = append(sources, caddyfileSource{name: name, content: string(content)})
+
+		name = ""
+		if match := extendsRegex.FindStringSubmatch(string(content)); match != nil {
+			name = match[1]
+		}
+	}
+
+	return sources, nil
+}
+
+// parseCaddyfileTemplate builds the template with its bases and all snippets. Legacy $NAME placeholders
+// are replaced in each part by substitute. Returns the template and all its sources joined.
+func parseCaddyfileTemplate(sitesAllPath, name string, substitute func(string) string) (*template.Template, string, error) {
+	sources, err := caddyfileSources(sitesAllPath, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The most basic template is executed, the ones extending it are parsed after it to override its blocks
+	base := sources[len(sources)-1]
+	parsed, err := template.New(base.name).Option("missingkey=zero").Parse(substitute(base.content))
+	if err != nil {
+		return nil, "", err
+	}
+
+	all := base.content
+	for i := len(sources) - 2; i >= 0; i-- {
+		if _, err = parsed.New(sources[i].name).Parse(substitute(sources[i].content)); err != nil {
+			return nil, "", err
+		}
+
+		all += sources[i].content
+	}
+
+	// Built-in snippet, which may be replaced by a snippet file of the same name
+	if _, err = parsed.New(HardeningSnippet).Parse(`{{.Hardening.Directives "\t"}}`); err != nil {
+		return nil, "", err
+	}
+
+	snippetsPath := path.Join(sitesAllPath, CaddyfileSnippetsDir)
+	entries, err := ioutil.ReadDir(snippetsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(path.Join(snippetsPath, entry.Name()))
+		if err != nil {
+			return nil, "", err
+		}
+
+		snippetName := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, err = parsed.New(snippetName).Parse(substitute(string(content))); err != nil {
+			return nil, "", fmt.Errorf("snippet %s: %w", entry.Name(), err)
+		}
+
+		all += string(content)
+	}
+
+	return parsed, all, nil
+}
+
+func ValidWwwMode(mode string) bool {
+	return mode == "" || mode == WwwNone || mode == WwwRedirect || mode == WwwAlias
+}
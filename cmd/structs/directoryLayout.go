@@ -0,0 +1,113 @@
+package structs
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// DirectoryLayout decides where files of a site live, relative to SERVER_FILES_DIR.
+type DirectoryLayout interface {
+	SiteRoot(cfg SiteConfig) (string, error)
+}
+
+// LayoutData is available in templates of PatternLayout.
+type LayoutData struct {
+	Domain string // Full domain, i.e. shop.example.com
+	Base   string // Registrable domain, i.e. example.com
+	Sub    string // Subdomain part, i.e. shop; empty for base domains
+}
+
+const (
+	LayoutNested  = "nested"
+	LayoutFlat    = "flat"
+	LayoutPattern = "pattern"
+)
+
+// DefaultLayout is used by all sites, set from the configuration at startup.
+var DefaultLayout DirectoryLayout = NestedLayout{}
+
+// NestedLayout puts every subdomain in its parent's "domains" directory, i.e. example.com/domains/shop.example.com.
+type NestedLayout struct{}
+
+func (NestedLayout) SiteRoot(cfg SiteConfig) (string, error) {
+	domainStructure := ReverseSlice(cfg.DomainStructure())
+	domainRootPath := ""
+	currentDomain := ""
+
+	for index, domain := range domainStructure {
+		if index > 0 {
+			currentDomain = fmt.Sprintf("%s.%s", domain, currentDomain)
+		} else {
+			currentDomain = domain
+		}
+		addPath := currentDomain
+
+		if index > 0 {
+			addPath = path.Join("domains", addPath)
+		}
+
+		domainRootPath = path.Join(domainRootPath, addPath)
+	}
+
+	return domainRootPath, nil
+}
+
+// FlatLayout puts every site directly in the base directory, i.e. shop.example.com.
+type FlatLayout struct{}
+
+func (FlatLayout) SiteRoot(cfg SiteConfig) (string, error) {
+	return cfg.DomainName, nil
+}
+
+// PatternLayout builds the path from a text/template with LayoutData,
+// i.e. {{.Base}}{{if .Sub}}/subdomains/{{.Sub}}{{end}}.
+type PatternLayout struct {
+	pattern *template.Template
+}
+
+func NewPatternLayout(pattern string) (PatternLayout, error) {
+	parsed, err := template.New("layout").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return PatternLayout{}, err
+	}
+
+	return PatternLayout{pattern: parsed}, nil
+}
+
+func (layout PatternLayout) SiteRoot(cfg SiteConfig) (string, error) {
+	structure := cfg.DomainStructure()
+	data := LayoutData{
+		Domain: cfg.DomainName,
+		Base:   structure[len(structure)-1],
+		Sub:    strings.Join(structure[:len(structure)-1], "."),
+	}
+
+	var out bytes.Buffer
+	if err := layout.pattern.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	root := path.Clean(strings.TrimSpace(out.String()))
+	if root == "." || path.IsAbs(root) {
+		return "", fmt.Errorf("layout pattern gives incorrect path %q for %s", out.String(), cfg.DomainName)
+	}
+
+	return root, nil
+}
+
+// NewDirectoryLayout creates a layout by its name; the pattern is used only by the pattern layout.
+func NewDirectoryLayout(name, pattern string) (DirectoryLayout, error) {
+	switch strings.ToLower(name) {
+	case "", LayoutNested:
+		return NestedLayout{}, nil
+	case LayoutFlat:
+		return FlatLayout{}, nil
+	case LayoutPattern:
+		return NewPatternLayout(pattern)
+	}
+
+	return nil, fmt.Errorf("unknown directory layout %q, use 'nested', 'flat' or 'pattern'", name)
+}
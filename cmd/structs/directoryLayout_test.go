@@ -0,0 +1,44 @@
+package structs
+
+import "testing"
+
+func TestDirectoryLayout_SiteRoot(t *testing.T) {
+	pattern, err := NewPatternLayout("{{.Base}}{{if .Sub}}/subdomains/{{.Sub}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		layout   DirectoryLayout
+		domain   string
+		expected string
+	}{
+		{NestedLayout{}, "example.com", "example.com"},
+		{NestedLayout{}, "test.example.com", "example.com/domains/test.example.com"},
+		{NestedLayout{}, "a.b.example.co.uk", "example.co.uk/domains/b.example.co.uk/domains/a.b.example.co.uk"},
+		{FlatLayout{}, "test.example.com", "test.example.com"},
+		{pattern, "example.com", "example.com"},
+		{pattern, "a.b.example.com", "example.com/subdomains/a.b"},
+	}
+
+	for _, table := range tables {
+		result, err := table.layout.SiteRoot(SiteConfig{DomainName: table.domain})
+
+		if err != nil || result != table.expected {
+			t.Errorf("Site root of %s built incorrectly by %T, expected %s, got %s (%v)", table.domain, table.layout, table.expected, result, err)
+		}
+	}
+}
+
+func TestPatternLayout_Escaping(t *testing.T) {
+	for _, pattern := range []string{"/srv/{{.Domain}}", "{{.Sub}}", "{{.Unknown}}"} {
+		layout, err := NewPatternLayout(pattern)
+		if err != nil {
+			continue
+		}
+
+		if _, err = layout.SiteRoot(SiteConfig{DomainName: "example.com"}); err == nil {
+			t.Errorf("Pattern %s should not give a site root", pattern)
+		}
+	}
+}
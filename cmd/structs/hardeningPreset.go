@@ -0,0 +1,169 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HardeningPreset is a named set of security headers and blocked paths, added to site blocks of sites using it.
+type HardeningPreset struct {
+	Name          string   `mapstructure:"name"`
+	Description   string   `mapstructure:"description"`
+	Headers       []string `mapstructure:"headers"`       // Response headers as "Name: value"
+	RemoveHeaders []string `mapstructure:"removeHeaders"` // Response headers to hide, i.e. Server
+	Block         []string `mapstructure:"block"`         // Path matchers answered with 404, i.e. */.* for dotfiles
+}
+
+// HardeningSnippet is the name of the built-in snippet rendering the site's preset, i.e. {{template "hardening" .}}.
+const HardeningSnippet = "hardening"
+
+var ErrUnknownHardeningPreset = errors.New("unknown hardening preset")
+
+var builtinHardeningPresets = []HardeningPreset{
+	{
+		Name:        "basic",
+		Description: "Safe headers for any website, hidden Server header, blocked dotfiles and credential files",
+		Headers: []string{
+			"X-Content-Type-Options: nosniff",
+			"X-Frame-Options: SAMEORIGIN",
+			"Referrer-Policy: strict-origin-when-cross-origin",
+		},
+		RemoveHeaders: []string{"Server"},
+		Block:         []string{"*/.*", "*/database_info.txt"},
+	},
+	{
+		Name:        "strict",
+		Description: "basic with HSTS, a same-origin Content Security Policy and no framing",
+		Headers: []string{
+			"Strict-Transport-Security: max-age=31536000; includeSubDomains",
+			"Content-Security-Policy: default-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'",
+			"X-Content-Type-Options: nosniff",
+			"X-Frame-Options: DENY",
+			"Referrer-Policy: strict-origin-when-cross-origin",
+			"Permissions-Policy: camera=(), microphone=(), geolocation=()",
+			"Cross-Origin-Opener-Policy: same-origin",
+		},
+		RemoveHeaders: []string{"Server"},
+		Block:         []string{"*/.*", "*/database_info.txt"},
+	},
+}
+
+var hardeningPresets = map[string]HardeningPreset{}
+
+func init() {
+	_ = RegisterHardeningPresets(nil)
+}
+
+// RegisterHardeningPresets replaces declared presets with the built-in ones followed by definitions.
+// A definition named like a built-in preset replaces it.
+func RegisterHardeningPresets(definitions []HardeningPreset) error {
+	registered := map[string]HardeningPreset{}
+
+	for i, preset := range append(append([]HardeningPreset{}, builtinHardeningPresets...), definitions...) {
+		preset.Name = strings.ToLower(strings.TrimSpace(preset.Name))
+		if len(preset.Name) == 0 {
+			return fmt.Errorf("hardening preset #%d has no name", i+1-len(builtinHardeningPresets))
+		}
+
+		for _, header := range preset.Headers {
+			if parts := strings.SplitN(header, ":", 2); len(parts) != 2 || !validHeaderName(parts[0]) {
+				return fmt.Errorf("header %q of hardening preset %s should look like Name: value", header, preset.Name)
+			}
+		}
+
+		for _, header := range preset.RemoveHeaders {
+			if !validHeaderName(header) {
+				return fmt.Errorf("removed header %q of hardening preset %s is not a header name", header, preset.Name)
+			}
+		}
+
+		for _, matcher := range preset.Block {
+			if len(matcher) == 0 || strings.ContainsAny(matcher, " \t\r\n{}") {
+				return fmt.Errorf("blocked path %q of hardening preset %s is not a path matcher", matcher, preset.Name)
+			}
+		}
+
+		registered[preset.Name] = preset
+	}
+
+	hardeningPresets = registered
+
+	return nil
+}
+
+func validHeaderName(name string) bool {
+	name = strings.TrimSpace(name)
+
+	return len(name) > 0 && !strings.ContainsAny(name, " \t\r\n\"{}:")
+}
+
+// GetHardeningPreset finds the preset by its name.
+func GetHardeningPreset(name string) (HardeningPreset, error) {
+	preset, ok := hardeningPresets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return HardeningPreset{}, fmt.Errorf("%w %s", ErrUnknownHardeningPreset, name)
+	}
+
+	return preset, nil
+}
+
+// HardeningPresets returns all available presets, sorted by name.
+func HardeningPresets() []HardeningPreset {
+	presets := make([]HardeningPreset, 0, len(hardeningPresets))
+	for _, preset := range hardeningPresets {
+		presets = append(presets, preset)
+	}
+
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+
+	return presets
+}
+
+// Directives renders blocking and header directives of the preset for a site block.
+// Empty for sites without a preset.
+func (preset HardeningPreset) Directives(indent string) string {
+	var out strings.Builder
+
+	out.WriteString(preset.blockDirective(indent, indent))
+
+	if len(preset.Headers) > 0 || len(preset.RemoveHeaders) > 0 {
+		_, _ = fmt.Fprintf(&out, "%sheader {\n", indent)
+		for _, header := range preset.Headers {
+			parts := strings.SplitN(header, ":", 2)
+			value := strings.ReplaceAll(strings.TrimSpace(parts[1]), `"`, `\"`)
+
+			_, _ = fmt.Fprintf(&out, "%s%s%s \"%s\"\n", indent, indent, strings.TrimSpace(parts[0]), value)
+		}
+		for _, header := range preset.RemoveHeaders {
+			_, _ = fmt.Fprintf(&out, "%s%s-%s\n", indent, indent, strings.TrimSpace(header))
+		}
+		_, _ = fmt.Fprintf(&out, "%s}\n", indent)
+	}
+
+	return out.String()
+}
+
+// blockDirective renders a route answering blocked paths with 404, with lines starting with prefix.
+// Caddy sorts handle and handle_path blocks before route, so requests they serve never reach a route of the site block:
+// the route is repeated inside each of them, where it is sorted before php_fastcgi, reverse_proxy and file_server.
+func (preset HardeningPreset) blockDirective(prefix, indent string) string {
+	if len(preset.Block) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+
+	_, _ = fmt.Fprintf(&out, "%sroute {\n", prefix)
+	_, _ = fmt.Fprintf(&out, "%s%s@hardening_blocked {\n", prefix, indent)
+	_, _ = fmt.Fprintf(&out, "%s%s%spath %s\n", prefix, indent, indent, strings.Join(preset.Block, " "))
+	_, _ = fmt.Fprintf(&out, "%s%s%snot path /.well-known/*\n", prefix, indent, indent)
+	_, _ = fmt.Fprintf(&out, "%s%s}\n", prefix, indent)
+	_, _ = fmt.Fprintf(&out, "%s%srespond @hardening_blocked 404\n", prefix, indent)
+	_, _ = fmt.Fprintf(&out, "%s}\n", prefix)
+
+	return out.String()
+}
@@ -0,0 +1,113 @@
+package structs
+
+import (
+	"errors"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRegisterHardeningPresets(t *testing.T) {
+	defer func() { _ = RegisterHardeningPresets(nil) }()
+
+	tables := []struct {
+		presets []HardeningPreset
+		valid   bool
+	}{
+		{[]HardeningPreset{{Name: "Intranet", Headers: []string{"X-Robots-Tag: noindex"}}}, true},
+		{[]HardeningPreset{{Name: "", Headers: []string{"X-Robots-Tag: noindex"}}}, false},
+		{[]HardeningPreset{{Name: "broken", Headers: []string{"X-Robots-Tag noindex"}}}, false},
+		{[]HardeningPreset{{Name: "broken", RemoveHeaders: []string{"Server: caddy"}}}, false},
+		{[]HardeningPreset{{Name: "broken", Block: []string{"/.git {"}}}, false},
+	}
+
+	for _, table := range tables {
+		if err := RegisterHardeningPresets(table.presets); (err == nil) != table.valid {
+			t.Errorf("Presets %v validity incorrect, expected %t, got error %v", table.presets, table.valid, err)
+		}
+	}
+
+	// Declared preset replaces the built-in one
+	if err := RegisterHardeningPresets([]HardeningPreset{{Name: "strict", RemoveHeaders: []string{"Server"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if preset, err := GetHardeningPreset("STRICT"); err != nil || len(preset.Headers) > 0 {
+		t.Errorf("Preset strict should be replaced, got %+v (%v)", preset, err)
+	}
+
+	if _, err := GetHardeningPreset("paranoid"); !errors.Is(err, ErrUnknownHardeningPreset) {
+		t.Errorf("Preset paranoid should be unknown, got %v", err)
+	}
+}
+
+func TestSiteConfig_RenderConfigHardening(t *testing.T) {
+	defer func() { _ = RegisterHardeningPresets(nil) }()
+	err := RegisterHardeningPresets([]HardeningPreset{{
+		Name:          "test",
+		Headers:       []string{`Content-Security-Policy: default-src 'self'; img-src "data:"`},
+		RemoveHeaders: []string{"Server"},
+		Block:         []string{"*/.*"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envConfig := utils.EnvironmentConfig{CaddySites: t.TempDir()}
+	sitesAll := path.Join(envConfig.CaddySites, "sites-all")
+
+	handlers := "\thandle /api/* {\n\t\treverse_proxy 127.0.0.1:3000\n\t}\n\thandle {\n\t\tfile_server\n\t}\n"
+
+	files := map[string]string{
+		"template_html":        "{{.SiteAddress}} {\n\troot * /srv\n}\n",
+		"template_php":         "{{.SiteAddress}} {\n{{template \"hardening\" .}}\troot * /srv\n}\n",
+		"template_application": "{{.SiteAddress}} {\n" + handlers + "}\n",
+	}
+
+	for name, content := range files {
+		if err := os.MkdirAll(sitesAll, 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(sitesAll, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	directives := "\troute {\n\t\t@hardening_blocked {\n\t\t\tpath */.*\n\t\t\tnot path /.well-known/*\n\t\t}\n\t\trespond @hardening_blocked 404\n\t}\n" +
+		"\theader {\n\t\tContent-Security-Policy \"default-src 'self'; img-src \\\"data:\\\"\"\n\t\t-Server\n\t}\n"
+
+	// Handle blocks are sorted before route by Caddy, so each of them blocks paths itself
+	blocked := "\t\troute {\n\t\t\t@hardening_blocked {\n\t\t\t\tpath */.*\n\t\t\t\tnot path /.well-known/*\n\t\t\t}\n\t\t\trespond @hardening_blocked 404\n\t\t}\n"
+	handled := "\thandle /api/* {\n" + blocked + "\t\treverse_proxy 127.0.0.1:3000\n\t}\n\thandle {\n" + blocked + "\t\tfile_server\n\t}\n"
+
+	tables := []struct {
+		programType utils.ProgramType
+		preset      string
+		expected    string
+	}{
+		{utils.ProgramTypeHtml, "test", "example.com {\n" + directives + "\troot * /srv\n}\n"},
+		{utils.ProgramTypePhp, "test", "example.com {\n" + directives + "\troot * /srv\n}\n"},
+		{utils.ProgramTypePhp, "", "example.com {\n\troot * /srv\n}\n"},
+		{utils.ProgramTypeApp, "test", "example.com {\n" + directives + handled + "}\n"},
+	}
+
+	for _, table := range tables {
+		cfg := SiteConfig{Type: table.programType, DomainName: "example.com", Preset: table.preset}
+
+		result, err := cfg.RenderConfig(envConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != table.expected {
+			t.Errorf("Caddyfile of %s with preset %q rendered incorrectly, expected %q, got %q", table.programType, table.preset, table.expected, string(result))
+		}
+	}
+
+	cfg := SiteConfig{Type: utils.ProgramTypeHtml, DomainName: "example.com", Preset: "paranoid"}
+	if _, err := cfg.RenderConfig(envConfig); !errors.Is(err, ErrUnknownHardeningPreset) {
+		t.Errorf("Unknown preset should fail rendering, got %v", err)
+	}
+}
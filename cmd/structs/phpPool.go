@@ -0,0 +1,164 @@
+package structs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// PhpPoolSettings configure PHP-FPM pools generated for PHP sites.
+type PhpPoolSettings struct {
+	PoolDirectory   string // i.e. /etc/php/8.0/fpm/pool.d
+	SocketDirectory string // i.e. /run/php
+	User            string // Used when the site has no system user of its own
+	ListenOwner     string // Owner of the socket, the web server user
+	ReloadCommand   string // i.e. systemctl reload php8.0-fpm
+
+	Pm              string
+	MaxChildren     int
+	StartServers    int
+	MinSpareServers int
+	MaxSpareServers int
+}
+
+// PhpPoolData is available in the pool template.
+type PhpPoolData struct {
+	PhpPoolSettings
+	Name      string
+	User      string
+	Socket    string
+	FilesRoot string
+}
+
+var ErrPhpPoolNotConfigured = errors.New("php-fpm pool directory is not configured")
+
+const phpPoolTemplate = `[{{.Name}}]
+user = {{.User}}
+group = {{.User}}
+
+listen = {{.Socket}}
+listen.owner = {{.ListenOwner}}
+listen.group = {{.ListenOwner}}
+listen.mode = 0660
+
+pm = {{.Pm}}
+pm.max_children = {{.MaxChildren}}
+{{- if eq .Pm "dynamic"}}
+pm.start_servers = {{.StartServers}}
+pm.min_spare_servers = {{.MinSpareServers}}
+pm.max_spare_servers = {{.MaxSpareServers}}
+{{- end}}
+
+php_admin_value[open_basedir] = {{.FilesRoot}}:/tmp
+`
+
+// PhpPoolName is the pool, socket and pool file name of the site.
+func (cfg SiteConfig) PhpPoolName() string {
+	return cfg.DomainName
+}
+
+// PhpSocketPath returns the socket the site's pool listens on.
+func (cfg SiteConfig) PhpSocketPath(settings PhpPoolSettings) string {
+	return path.Join(settings.SocketDirectory, cfg.PhpPoolName()+".sock")
+}
+
+func (cfg SiteConfig) phpPoolPath(settings PhpPoolSettings) string {
+	return path.Join(settings.PoolDirectory, cfg.PhpPoolName()+".conf")
+}
+
+// RenderPhpPool builds the pool config, running PHP as the site's system user, limited to its files root.
+func (cfg SiteConfig) RenderPhpPool(settings PhpPoolSettings) ([]byte, error) {
+	data := PhpPoolData{
+		PhpPoolSettings: settings,
+		Name:            cfg.PhpPoolName(),
+		User:            cfg.SystemUser,
+		Socket:          cfg.PhpSocketPath(settings),
+		FilesRoot:       cfg.filesRoot,
+	}
+
+	if len(data.User) == 0 {
+		data.User = settings.User
+	}
+
+	if len(data.Pm) == 0 {
+		data.Pm = "dynamic"
+	}
+
+	parsed, err := template.New("pool").Parse(phpPoolTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err = parsed.Execute(&out, data); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// CreatePhpPool writes the pool config and remembers its socket for the Caddyfile.
+// An existing pool of the same name is never overwritten, fs.ErrExist is returned instead.
+func (cfg *SiteConfig) CreatePhpPool(settings PhpPoolSettings) (bool, error) {
+	if len(settings.PoolDirectory) == 0 {
+		return false, ErrPhpPoolNotConfigured
+	}
+
+	content, err := cfg.RenderPhpPool(settings)
+	if err != nil {
+		return false, err
+	}
+
+	file, err := os.OpenFile(cfg.phpPoolPath(settings), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cfg.PhpSocket = cfg.PhpSocketPath(settings)
+
+	return true, nil
+}
+
+// RemovePhpPool deletes the pool config of the site, if there is one.
+func (cfg *SiteConfig) RemovePhpPool(settings PhpPoolSettings) (bool, error) {
+	if len(cfg.PhpSocket) == 0 {
+		return false, nil
+	}
+
+	if err := os.Remove(cfg.phpPoolPath(settings)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	cfg.PhpSocket = ""
+
+	return true, nil
+}
+
+// ReloadPhpFpm runs the configured reload command, so php-fpm picks up changed pools.
+// Returns false without error if there is no reload command.
+func ReloadPhpFpm(settings PhpPoolSettings) (bool, error) {
+	command := strings.Fields(settings.ReloadCommand)
+	if len(command) == 0 {
+		return false, nil
+	}
+
+	out, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w: %s", settings.ReloadCommand, err, out)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package structs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSiteConfig_RenderPhpPool(t *testing.T) {
+	settings := PhpPoolSettings{
+		SocketDirectory: "/run/php",
+		User:            "www-data",
+		ListenOwner:     "caddy",
+		Pm:              "ondemand",
+		MaxChildren:     8,
+	}
+
+	tables := []struct {
+		config   SiteConfig
+		expected []string
+		missing  []string
+	}{
+		{
+			SiteConfig{DomainName: "example.com", SystemUser: "example", filesRoot: "/srv/example.com"},
+			[]string{"[example.com]", "user = example", "listen = /run/php/example.com.sock", "listen.owner = caddy", "pm = ondemand", "pm.max_children = 8", "open_basedir] = /srv/example.com:/tmp"},
+			[]string{"pm.start_servers"},
+		},
+		{
+			SiteConfig{DomainName: "shop.example.com", filesRoot: "/srv/shop"},
+			[]string{"[shop.example.com]", "user = www-data", "group = www-data"},
+			nil,
+		},
+	}
+
+	for _, table := range tables {
+		rendered, err := table.config.RenderPhpPool(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, line := range table.expected {
+			if !strings.Contains(string(rendered), line) {
+				t.Errorf("Pool of %s incorrect, expected %s in:\n%s", table.config.DomainName, line, rendered)
+			}
+		}
+
+		for _, line := range table.missing {
+			if strings.Contains(string(rendered), line) {
+				t.Errorf("Pool of %s incorrect, unexpected %s in:\n%s", table.config.DomainName, line, rendered)
+			}
+		}
+	}
+}
+
+func TestSiteConfig_CreatePhpPool(t *testing.T) {
+	settings := PhpPoolSettings{PoolDirectory: t.TempDir(), SocketDirectory: "/run/php", User: "www-data", Pm: "ondemand"}
+	site := SiteConfig{DomainName: "example.com", filesRoot: "/srv/example.com"}
+
+	if ok, err := site.CreatePhpPool(settings); !ok {
+		t.Fatal(err)
+	}
+
+	if site.PhpSocket != "/run/php/example.com.sock" {
+		t.Errorf("Wrong socket, expected /run/php/example.com.sock, got %s", site.PhpSocket)
+	}
+
+	// Existing pools, i.e. of another site or written by hand, are never overwritten
+	again := SiteConfig{DomainName: "example.com", filesRoot: "/srv/other"}
+	if ok, err := again.CreatePhpPool(settings); ok || !os.IsExist(err) {
+		t.Errorf("Expected existing pool error, got %v", err)
+	}
+
+	pool, err := ioutil.ReadFile(path.Join(settings.PoolDirectory, "example.com.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(pool), "/srv/example.com:/tmp") {
+		t.Errorf("Existing pool overwritten:\n%s", pool)
+	}
+}
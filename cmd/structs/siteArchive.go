@@ -0,0 +1,51 @@
+package structs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	ArchiveManifestName  = "manifest.json"
+	ArchiveFilesDir      = "files"
+	ArchiveCaddyfileName = "Caddyfile"
+)
+
+// SiteArchiveManifest describes the content of an archive created by the archive command.
+type SiteArchiveManifest struct {
+	Version      int        `json:"version"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	Site         SiteRecord `json:"site"`
+	DatabaseDump string     `json:"databaseDump,omitempty"`
+}
+
+func NewSiteArchiveManifest(record SiteRecord) SiteArchiveManifest {
+	manifest := SiteArchiveManifest{
+		Version:   1,
+		CreatedAt: time.Now(),
+		Site:      record,
+	}
+
+	// Credentials are not moved between servers, restore creates new ones
+	if record.Database != nil {
+		database := *record.Database
+		database.Password = ""
+		manifest.Site.Database = &database
+	}
+
+	return manifest
+}
+
+func ReadSiteArchiveManifest(file string) (SiteArchiveManifest, error) {
+	var manifest SiteArchiveManifest
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return manifest, err
+	}
+
+	err = json.Unmarshal(content, &manifest)
+
+	return manifest, err
+}
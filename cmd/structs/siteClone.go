@@ -0,0 +1,59 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	copyDirs "github.com/otiai10/copy"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Cloned returns a copy of the site under another domain. Aliases, former domains and the www counterpart
+// stay with the original site, as do its Caddyfile, php-fpm pool and systemd unit.
+func (cfg SiteConfig) Cloned(envConfig utils.EnvironmentConfig, domain, displayName string) (SiteConfig, error) {
+	clone, err := cfg.Renamed(envConfig, domain, displayName)
+	if err != nil {
+		return SiteConfig{}, err
+	}
+
+	clone.Aliases = nil
+	clone.RedirectFrom = nil
+	clone.Www = WwwNone
+	clone.SystemUser = ""
+	clone.BasicAuth = nil
+
+	return clone, nil
+}
+
+// CopyFileStructure copies the files root of the site to the one of destination, which can't exist yet.
+// Copies keep owners of the original files. Directories in skip (i.e. files roots of nested sites) are not copied.
+func (cfg SiteConfig) CopyFileStructure(envConfig utils.EnvironmentConfig, destination SiteConfig, skip []string) (bool, error) {
+	if !isInside(cfg.filesRoot, envConfig.ServerFiles) || !isInside(destination.filesRoot, envConfig.ServerFiles) {
+		return false, ErrUnsafePath
+	}
+
+	if _, err := os.Lstat(destination.filesRoot); err == nil {
+		return false, fs.ErrExist
+	}
+
+	// Destination may be nested in the copied directory
+	skip = append([]string{destination.filesRoot}, skip...)
+
+	err := copyDirs.Copy(cfg.filesRoot, destination.filesRoot, copyDirs.Options{
+		PreserveOwner: true,
+		Skip: func(src string) (bool, error) {
+			for _, skipped := range skip {
+				if filepath.Clean(src) == filepath.Clean(skipped) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSiteConfig_CopyFileStructure(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{ServerFiles: t.TempDir()}
+	site := SiteConfig{DomainName: "example.com", Aliases: []string{"example.org"}, Www: WwwRedirect}
+	site.filesRoot, _ = site.DomainRootPath(envConfig)
+
+	nested := path.Join(site.filesRoot, "domains", "shop.example.com")
+	for _, dir := range []string{path.Join(site.filesRoot, "public_html"), nested} {
+		if err := os.MkdirAll(dir, 0775); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clone, err := site.Cloned(envConfig, "staging.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clone.Aliases != nil || clone.Www != WwwNone {
+		t.Errorf("Clone should not take over aliases and www, got %v and %s", clone.Aliases, clone.Www)
+	}
+
+	if ok, err := site.CopyFileStructure(envConfig, clone, []string{nested}); !ok {
+		t.Fatal(err)
+	}
+
+	if !directoryExists(path.Join(clone.filesRoot, "public_html")) {
+		t.Errorf("Files were not copied to %s", clone.filesRoot)
+	}
+
+	for _, skipped := range []string{path.Join(clone.filesRoot, "domains", "shop.example.com"), path.Join(clone.filesRoot, "domains", "staging.example.com")} {
+		if directoryExists(skipped) {
+			t.Errorf("%s should not be copied", skipped)
+		}
+	}
+}
+
+func TestSiteConfig_Cloned(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{ServerFiles: "/srv"}
+
+	tables := []struct {
+		tls      SiteTls
+		expected SiteTls
+	}{
+		// A custom certificate does not cover the clone, which falls back to the template's handling
+		{SiteTls{Mode: TlsCustom, Cert: "/etc/ssl/example.com.pem", Key: "/etc/ssl/example.com.key"}, SiteTls{}},
+		{SiteTls{Mode: TlsInternal}, SiteTls{Mode: TlsInternal}},
+		{SiteTls{}, SiteTls{}},
+	}
+
+	for _, table := range tables {
+		site := SiteConfig{Type: utils.ProgramTypeHtml, DomainName: "example.com", Aliases: []string{"example.net"}, Tls: table.tls}
+
+		clone, err := site.Cloned(envConfig, "staging.example.com", "staging.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(clone.Tls, table.expected) {
+			t.Errorf("Wrong tls of a clone of %s site, expected %v, got %v", table.tls.Mode, table.expected, clone.Tls)
+		}
+
+		if len(clone.Aliases) > 0 {
+			t.Errorf("Aliases of the source should not be cloned, got %s", clone.Aliases)
+		}
+	}
+}
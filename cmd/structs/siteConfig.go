@@ -13,19 +13,37 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 type SiteConfig struct {
-	Type       utils.ProgramType
-	DomainName string
-	Port       int
-	ForceBase  bool
-	caddyfile  string
-	filesRoot  string
+	Type         utils.ProgramType
+	DomainName   string // ASCII (punycode) form, used for Caddy and paths
+	DisplayName  string // Unicode form of internationalized domains
+	Port         int
+	Upstreams    []string // host:port of application instances, 127.0.0.1:Port if empty
+	LbPolicy     string   // Load balancing policy of reverse_proxy, Caddy's default if empty
+	HealthPath   string   // Active health check URI
+	HealthEvery  string   // Interval of active health checks, i.e. 10s
+	ForceBase    bool
+	Vars         map[string]string // Custom template variables, available as $NAME and {{.Vars.name}}
+	Aliases      []string          // Additional domains served from the same root
+	Www          string            // Handling of the www counterpart: none, redirect or alias
+	RedirectFrom []string          // Former domains of the site, permanently redirected to it
+	BasicAuth    []BasicAuthUser   // Users allowed in, if the site is protected with basic auth
+	Tls          SiteTls           // Certificate handling, left to the template if mode is empty
+	Preset       string            // Name of the hardening preset applied to the site
+	SystemUser   string            // Linux account owning the files root, if the site has its own
+	PhpSocket    string            // Socket of the site's own PHP-FPM pool
+	Service      string            // systemd unit running the application
+	caddyfile    string
+	filesRoot    string
 }
 
+var ErrUnsafePath = errors.New("path escapes the base directory")
+
 func (cfg SiteConfig) Caddyfile() string {
 	return cfg.caddyfile
 }
@@ -34,44 +52,180 @@ func (cfg SiteConfig) FilesRoot() string {
 	return cfg.filesRoot
 }
 
+// Name returns the display name of the domain, falling back to the ASCII one.
+func (cfg SiteConfig) Name() string {
+	if len(cfg.DisplayName) > 0 {
+		return cfg.DisplayName
+	}
+
+	return cfg.DomainName
+}
+
+// ApplyTypeDefaults fills variables and port not given for the site from its type declaration,
+// then checks that all variables required by the type are set.
+func (cfg *SiteConfig) ApplyTypeDefaults() error {
+	definition := cfg.Type.Definition()
+
+	for name, value := range definition.Vars {
+		if _, ok := cfg.Vars[name]; !ok {
+			if cfg.Vars == nil {
+				cfg.Vars = map[string]string{}
+			}
+
+			cfg.Vars[name] = value
+		}
+	}
+
+	if cfg.Port == 0 && len(cfg.Upstreams) == 0 && definition.Base == utils.ProgramTypeApp {
+		cfg.Port = definition.Port
+	}
+
+	var missing []string
+	for _, name := range definition.RequiredVars {
+		if len(cfg.Vars[name]) == 0 {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("website type %s requires variables: %s", strings.ToLower(string(cfg.Type)), strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// ChangeType switches the site to another type, dropping variables still holding defaults of the previous type.
+// Defaults of the new type are filled in by ApplyTypeDefaults.
+func (cfg *SiteConfig) ChangeType(programType utils.ProgramType) {
+	for name, value := range cfg.Type.Definition().Vars {
+		if current, ok := cfg.Vars[name]; ok && current == value {
+			delete(cfg.Vars, name)
+		}
+	}
+
+	cfg.Type = programType
+}
+
+// UserName builds a name for the site's accounts from the domain. I.e. when domain is example.com - name is example.
+// When domain is test.example.com - name is test_example.
+func (cfg SiteConfig) UserName() string {
+	domainParts := cfg.DomainStructure(true)
+
+	return strings.Split(strings.Join(domainParts, "_"), ".")[0] // Get rid of TLD
+}
+
 // Functions regarding Caddy
 
 func (cfg *SiteConfig) CreateConfig(envConfig utils.EnvironmentConfig) (bool, error) {
-	caddyfileNameFormat := "%s.Caddyfile"
+	// Check, if Caddyfile for this domain do not already exist
+	if fileExists(cfg.caddyfilePath(envConfig)) {
+		return false, fs.ErrExist
+	}
+
+	content, err := cfg.RenderConfig(envConfig)
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.WriteConfig(envConfig, content)
+}
 
+// RenderConfig fills the Caddyfile template of the site's type, without writing it anywhere.
+func (cfg SiteConfig) RenderConfig(envConfig utils.EnvironmentConfig) ([]byte, error) {
 	// Set locations
 	sitesAllPath := path.Join(envConfig.CaddySites, "sites-all")
 
 	// Get template path
-	templateName := fmt.Sprintf("template_%s", strings.ToLower(string(cfg.Type)))
+	templateName := cfg.Type.Definition().CaddyfileTemplateName()
 	templatePath := path.Join(sitesAllPath, templateName)
 
-	// Create Caddyfile path
-	caddyfileDestinationName := fmt.Sprintf(caddyfileNameFormat, cfg.DomainName)
-	destinationPath := path.Join(sitesAllPath, caddyfileDestinationName)
-
 	if !fileExists(templatePath) {
-		return false, fs.ErrNotExist
+		return nil, fs.ErrNotExist
 	}
 
-	// Check, if Caddyfile for this domain do not already exist
-	if fileExists(destinationPath) {
-		return false, fs.ErrExist
+	if len(cfg.Preset) > 0 {
+		if _, err := GetHardeningPreset(cfg.Preset); err != nil {
+			return nil, err
+		}
+	}
+
+	data := cfg.TemplateData()
+
+	// Legacy placeholders in template, its bases and snippets become template actions. Values are only
+	// inserted when the template is executed, so they are never parsed as template code.
+	placeholders := map[string]string{
+		"$SITE_ADDRESS": "{{.SiteAddress}}",
+		"$FILES_ROOT":   "{{.FilesRoot}}",
+		"$PORT":         "{{.Port}}",
+		"$DISPLAY_NAME": "{{.DisplayName}}",
+		"$PHP_SOCKET":   "{{.PhpSocket}}",
+		"$UPSTREAMS":    "{{.UpstreamList}}",
+	}
+
+	for name := range cfg.Vars {
+		placeholders["$"+strings.ToUpper(name)] = fmt.Sprintf("{{index .Vars %s}}", strconv.Quote(name))
+	}
+
+	// Replacer tries placeholders in the given order, longest first
+	var replacements []string
+	for _, name := range sortedKeys(placeholders) {
+		replacements = append(replacements, name, placeholders[name])
+	}
+
+	substitute := strings.NewReplacer(replacements...).Replace
+
+	parsed, sources, err := parseCaddyfileTemplate(sitesAllPath, templateName, substitute)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeCaddyfile(parsed, sources, data)
+}
+
+// UpdateConfig renders the Caddyfile again, overwriting the existing one. Returns false if nothing changed.
+func (cfg *SiteConfig) UpdateConfig(envConfig utils.EnvironmentConfig) (bool, error) {
+	destinationPath := cfg.caddyfilePath(envConfig)
+
+	if !isInside(destinationPath, path.Join(envConfig.CaddySites, "sites-all")) {
+		return false, ErrUnsafePath
 	}
 
-	// Read template
-	template, err := ioutil.ReadFile(templatePath)
+	content, err := cfg.RenderConfig(envConfig)
 	if err != nil {
+		return false, err
+	}
+
+	current, err := ioutil.ReadFile(destinationPath)
+	if err == nil && bytes.Equal(current, content) {
+		cfg.caddyfile = destinationPath
 		return false, nil
 	}
 
-	// Replace vars in template
-	templateSpecific := strings.ReplaceAll(string(template), "$SITE_ADDRESS", cfg.DomainName)
-	templateSpecific = strings.ReplaceAll(templateSpecific, "$FILES_ROOT", cfg.filesRoot)
-	templateSpecific = strings.ReplaceAll(templateSpecific, "$PORT", strconv.Itoa(cfg.Port))
+	err = ioutil.WriteFile(destinationPath, content, 0775)
+	if err != nil {
+		return false, err
+	}
+
+	cfg.caddyfile = destinationPath
+
+	return true, nil
+}
+
+// WriteConfig writes already rendered Caddyfile of the site to sites-all, i.e. one restored from an archive.
+func (cfg *SiteConfig) WriteConfig(envConfig utils.EnvironmentConfig, content []byte) (bool, error) {
+	destinationPath := cfg.caddyfilePath(envConfig)
+
+	if !isInside(destinationPath, path.Join(envConfig.CaddySites, "sites-all")) {
+		return false, ErrUnsafePath
+	}
+
+	// Check, if Caddyfile for this domain do not already exist
+	if fileExists(destinationPath) {
+		return false, fs.ErrExist
+	}
 
 	// Write Caddyfile
-	err = ioutil.WriteFile(destinationPath, []byte(templateSpecific), 0775)
+	err := ioutil.WriteFile(destinationPath, content, 0775)
 	if err != nil {
 		return false, err
 	}
@@ -81,6 +235,12 @@ func (cfg *SiteConfig) CreateConfig(envConfig utils.EnvironmentConfig) (bool, er
 	return true, nil
 }
 
+func (cfg SiteConfig) caddyfilePath(envConfig utils.EnvironmentConfig) string {
+	caddyfileNameFormat := "%s.Caddyfile"
+
+	return path.Join(envConfig.CaddySites, "sites-all", fmt.Sprintf(caddyfileNameFormat, cfg.DomainName))
+}
+
 func (cfg SiteConfig) EnableSite(envConfig utils.EnvironmentConfig) (bool, error) {
 	// Set locations
 	sitesEnabledPath := path.Join(envConfig.CaddySites, "sites-enabled")
@@ -101,7 +261,38 @@ func (cfg SiteConfig) EnableSite(envConfig utils.EnvironmentConfig) (bool, error
 	return true, nil
 }
 
-func (cfg SiteConfig) ReloadCaddy(envConfig utils.EnvironmentConfig) {
+func (cfg SiteConfig) DisableSite(envConfig utils.EnvironmentConfig) (bool, error) {
+	linkPath := path.Join(envConfig.CaddySites, "sites-enabled", filepath.Base(cfg.caddyfilePath(envConfig)))
+
+	if _, err := os.Lstat(linkPath); os.IsNotExist(err) {
+		return false, fs.ErrNotExist
+	}
+
+	if err := os.Remove(linkPath); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (cfg SiteConfig) IsEnabled(envConfig utils.EnvironmentConfig) bool {
+	_, err := os.Lstat(path.Join(envConfig.CaddySites, "sites-enabled", filepath.Base(cfg.caddyfilePath(envConfig))))
+
+	return err == nil
+}
+
+// RemoveConfig deletes the site's Caddyfile from sites-all. The site should be disabled first.
+func (cfg *SiteConfig) RemoveConfig(envConfig utils.EnvironmentConfig) (bool, error) {
+	if err := os.Remove(cfg.caddyfilePath(envConfig)); err != nil {
+		return false, err
+	}
+
+	cfg.caddyfile = ""
+
+	return true, nil
+}
+
+func (cfg SiteConfig) ReloadCaddy(envConfig utils.EnvironmentConfig) (bool, error) {
 	println("Reloading Caddy...")
 
 	cmd := exec.Command("caddy", "reload", "--config", path.Join(envConfig.CaddySites, "Caddyfile"))
@@ -113,10 +304,28 @@ func (cfg SiteConfig) ReloadCaddy(envConfig utils.EnvironmentConfig) {
 	if err != nil {
 		println(fmt.Sprintf("There was an error while reloading Caddy: %s", err.Error()))
 		println(out.String())
-		return
+		return false, err
 	}
 
 	println(out.String())
+
+	return true, nil
+}
+
+// ValidateCaddy checks the whole Caddy configuration, including all enabled sites.
+func (cfg SiteConfig) ValidateCaddy(envConfig utils.EnvironmentConfig) (bool, error) {
+	cmd := exec.Command("caddy", "validate", "--config", path.Join(envConfig.CaddySites, "Caddyfile"), "--adapter", "caddyfile")
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if err != nil {
+		return false, fmt.Errorf("caddy configuration is not valid: %w\n%s", err, out.String())
+	}
+
+	return true, nil
 }
 
 // Functions regarding file structure
@@ -124,34 +333,27 @@ func (cfg SiteConfig) ReloadCaddy(envConfig utils.EnvironmentConfig) {
 func (cfg *SiteConfig) CreateFileStructure(envConfig utils.EnvironmentConfig) (bool, error) {
 	// Set locations
 	templatesBasePath := path.Join(envConfig.ServerFiles, "templates")
-	templatePath := path.Join(templatesBasePath, strings.ToLower(string(cfg.Type)))
+	templatePath := path.Join(templatesBasePath, cfg.Type.Definition().FilesTemplateName())
 
-	if !directoryExists(templatePath) {
-		return false, fs.ErrNotExist
+	// Files root is known even if the structure is not copied, so the Caddyfile can still point to it
+	domainRootPath, err := cfg.DomainRootPath(envConfig)
+	if err != nil {
+		return false, err
 	}
 
-	// Create target path
-	domainStructure := ReverseSlice(cfg.DomainStructure())
-	domainRootPath := ""
-	currentDomain := ""
+	if !isInside(domainRootPath, envConfig.ServerFiles) {
+		return false, ErrUnsafePath
+	}
 
-	for index, domain := range domainStructure {
-		if index > 0 {
-			currentDomain = fmt.Sprintf("%s.%s", domain, currentDomain)
-		} else {
-			currentDomain = domain
-		}
-		addPath := currentDomain
+	cfg.filesRoot = domainRootPath
 
-		if index > 0 {
-			addPath = path.Join("domains", addPath)
-		}
+	if !directoryExists(templatePath) {
+		// Without a template the site still gets its (empty) root
+		_ = os.MkdirAll(domainRootPath, 0775)
 
-		domainRootPath = path.Join(domainRootPath, addPath)
+		return false, fs.ErrNotExist
 	}
 
-	domainRootPath = path.Join(envConfig.ServerFiles, domainRootPath)
-
 	if directoryExists(domainRootPath) {
 		dir, err := os.Open(domainRootPath)
 		if err != nil {
@@ -162,19 +364,22 @@ func (cfg *SiteConfig) CreateFileStructure(envConfig utils.EnvironmentConfig) (b
 		}(dir)
 
 		_, err = dir.Readdirnames(1)
-		if err == io.EOF {
+		if err != io.EOF {
 			return false, errors.New("domain directory not empty")
 		}
 	}
 
-	err := os.MkdirAll(domainRootPath, 0775)
+	err = os.MkdirAll(domainRootPath, 0775)
 	if err != nil {
 		return false, err
 	}
 
-	cfg.filesRoot = domainRootPath
-
-	err = copyDirs.Copy(templatePath, domainRootPath)
+	err = copyDirs.Copy(templatePath, domainRootPath, copyDirs.Options{
+		Skip: func(src string) (bool, error) {
+			// Unit template is installed by systemd step, not served with the files
+			return src == path.Join(templatePath, SystemdUnitTemplateName), nil
+		},
+	})
 	if err != nil {
 		return false, err
 	}
@@ -186,7 +391,7 @@ func (cfg *SiteConfig) CreateFileStructure(envConfig utils.EnvironmentConfig) (b
 
 		if err == nil {
 			// Replace vars in template
-			indexOverwritten := strings.ReplaceAll(string(indexFile), "${DOM}", cfg.DomainName)
+			indexOverwritten := strings.ReplaceAll(string(indexFile), "${DOM}", cfg.Name())
 
 			// Write index file
 			_ = ioutil.WriteFile(indexPath, []byte(indexOverwritten), 0775)
@@ -196,39 +401,196 @@ func (cfg *SiteConfig) CreateFileStructure(envConfig utils.EnvironmentConfig) (b
 	return true, nil
 }
 
+// OverlayFileStructure copies files of the site type's template which are missing in the existing files root.
+// Existing files are never overwritten. Returns paths of copied files and directories.
+func (cfg SiteConfig) OverlayFileStructure(envConfig utils.EnvironmentConfig) ([]string, error) {
+	templatePath := path.Join(envConfig.ServerFiles, "templates", cfg.Type.Definition().FilesTemplateName())
+
+	if !directoryExists(templatePath) {
+		return nil, fs.ErrNotExist
+	}
+
+	if !isInside(cfg.filesRoot, envConfig.ServerFiles) {
+		return nil, ErrUnsafePath
+	}
+
+	var created []string
+
+	err := filepath.WalkDir(templatePath, func(source string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(templatePath, source)
+		if err != nil || relative == "." || relative == SystemdUnitTemplateName {
+			return err
+		}
+
+		destination := path.Join(cfg.filesRoot, relative)
+		if _, err = os.Lstat(destination); err == nil {
+			return nil
+		}
+
+		if entry.IsDir() {
+			err = os.MkdirAll(destination, 0775)
+		} else {
+			err = copyDirs.Copy(source, destination)
+		}
+		if err != nil {
+			return err
+		}
+
+		created = append(created, destination)
+
+		// Replace vars in the new index, like in a new site
+		if relative == path.Join("public_html", "index.html") {
+			if content, err := ioutil.ReadFile(destination); err == nil {
+				_ = ioutil.WriteFile(destination, []byte(strings.ReplaceAll(string(content), "${DOM}", cfg.Name())), 0775)
+			}
+		}
+
+		return nil
+	})
+
+	return created, err
+}
+
+// Renamed returns the site moved to another domain, with the files root following the directory layout.
+// Caddyfile, php-fpm pool and systemd unit are not carried over, as they have to be created for the new domain,
+// and neither is a custom certificate, which does not cover it.
+func (cfg SiteConfig) Renamed(envConfig utils.EnvironmentConfig, domain, displayName string) (SiteConfig, error) {
+	renamed := cfg
+	renamed.DomainName = domain
+	renamed.DisplayName = displayName
+	renamed.Aliases = withoutDomain(cfg.Aliases, domain)
+	renamed.RedirectFrom = withoutDomain(cfg.RedirectFrom, domain)
+	renamed.PhpSocket = ""
+	renamed.Service = ""
+	renamed.caddyfile = ""
+
+	if cfg.Tls.Mode == TlsCustom {
+		renamed.Tls = SiteTls{}
+	}
+
+	root, err := renamed.DomainRootPath(envConfig)
+	if err != nil {
+		return SiteConfig{}, err
+	}
+
+	renamed.filesRoot = root
+
+	return renamed, nil
+}
+
+// MoveFileStructure moves the files root of the site to the one of destination, which can't exist yet.
+func (cfg SiteConfig) MoveFileStructure(envConfig utils.EnvironmentConfig, destination SiteConfig) (bool, error) {
+	if !isInside(cfg.filesRoot, envConfig.ServerFiles) || !isInside(destination.filesRoot, envConfig.ServerFiles) {
+		return false, ErrUnsafePath
+	}
+
+	if isInside(destination.filesRoot, cfg.filesRoot) {
+		return false, fmt.Errorf("%s is inside %s", destination.filesRoot, cfg.filesRoot)
+	}
+
+	if _, err := os.Lstat(destination.filesRoot); err == nil {
+		return false, fs.ErrExist
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination.filesRoot), 0775); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(cfg.filesRoot, destination.filesRoot); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DomainRootPath builds the files root of the site using the configured directory layout.
+func (cfg SiteConfig) DomainRootPath(envConfig utils.EnvironmentConfig) (string, error) {
+	root, err := DefaultLayout.SiteRoot(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(envConfig.ServerFiles, root), nil
+}
+
+// DomainStructure splits the domain into subdomain labels followed by the registrable domain,
+// i.e. shop.example.co.uk into shop and example.co.uk. The registrable domain is found using the
+// Public Suffix List, unless ForceBase is set (and not ignored).
 func (cfg SiteConfig) DomainStructure(ignore ...bool) []string {
 	if cfg.ForceBase && (len(ignore) == 0 || ignore[0] == false) {
 		return []string{cfg.DomainName}
 	}
 
-	splitted := strings.Split(cfg.DomainName, ".")
-
-	if len(splitted) == 1 {
-		return splitted
-	}
-	if len(splitted) == 0 {
+	if len(cfg.DomainName) == 0 {
 		return []string{}
 	}
 
-	splitted[len(splitted)-2] = splitted[len(splitted)-2] + "." + splitted[len(splitted)-1]
-	splitted = splitted[:len(splitted)-1]
+	registrable := utils.RegistrableDomain(cfg.DomainName)
+
+	// Single labels and public suffixes themselves have no structure
+	if len(registrable) == 0 || registrable == cfg.DomainName {
+		return []string{cfg.DomainName}
+	}
+
+	subdomains := strings.Split(strings.TrimSuffix(cfg.DomainName, "."+registrable), ".")
 
-	return splitted
+	return append(subdomains, registrable)
 }
 
-func (cfg SiteConfig) WriteDatabaseInfo(host string, port int, db, username, password, userHost string) bool {
-	// Set location
-	fileName := path.Join(cfg.filesRoot, "database_info.txt")
+// DatabaseInfoPath is the file in the files root holding database credentials of the site.
+func (cfg SiteConfig) DatabaseInfoPath() string {
+	return path.Join(cfg.filesRoot, "database_info.txt")
+}
+
+// WriteDatabaseInfo stores database credentials, readable only by the owner and the group of the file.
+func (cfg SiteConfig) WriteDatabaseInfo(host string, port int, db, username, password, userHost string) (bool, error) {
+	fileName := cfg.DatabaseInfoPath()
 
 	content := fmt.Sprintf("Database address: %s:%d, database name: %s\nUsername: %s, password: %s\nAccess restricted to %s", host, port, db, username, password, userHost)
 
-	// Write to file
-	err := ioutil.WriteFile(fileName, []byte(content), 0775)
-	if err != nil {
-		return false
+	if err := ioutil.WriteFile(fileName, []byte(content), utils.SiteFileMode); err != nil {
+		return false, err
+	}
+
+	// Existing files keep their mode when written
+	if err := os.Chmod(fileName, utils.SiteFileMode); err != nil {
+		return false, err
 	}
 
-	return true
+	return true, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	// Longest first, so $PHP_VERSION is not broken by replacing $PHP
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+func withoutDomain(domains []string, domain string) []string {
+	var result []string
+	for _, name := range domains {
+		if name != domain {
+			result = append(result, name)
+		}
+	}
+
+	return result
 }
 
 func ReverseSlice(slice []string) []string {
@@ -239,6 +601,16 @@ func ReverseSlice(slice []string) []string {
 	return slice
 }
 
+// isInside checks, if the path is located below the base directory (and is not the base itself).
+func isInside(target, base string) bool {
+	relative, err := filepath.Rel(filepath.Clean(base), filepath.Clean(target))
+	if err != nil {
+		return false
+	}
+
+	return relative != "." && relative != ".." && !strings.HasPrefix(relative, "../")
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 
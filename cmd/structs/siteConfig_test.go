@@ -1,6 +1,10 @@
 package structs
 
 import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 )
@@ -12,6 +16,12 @@ func TestSiteConfig_DomainStructure(t *testing.T) {
 	}{
 		{"example.com", []string{"example.com"}},
 		{"test.example.com", []string{"test", "example.com"}},
+		{"example.co.uk", []string{"example.co.uk"}},
+		{"shop.example.co.uk", []string{"shop", "example.co.uk"}},
+		{"a.b.example.com.pl", []string{"a", "b", "example.com.pl"}},
+		{"blog.user.github.io", []string{"blog", "user.github.io"}},
+		{"test.example.internal", []string{"test", "example.internal"}},
+		{"localhost", []string{"localhost"}},
 		{"", []string{}},
 	}
 
@@ -25,6 +35,18 @@ func TestSiteConfig_DomainStructure(t *testing.T) {
 	}
 }
 
+func TestSiteConfig_DomainStructureForceBase(t *testing.T) {
+	cfg := SiteConfig{DomainName: "shop.example.co.uk", ForceBase: true}
+
+	if result := cfg.DomainStructure(); !reflect.DeepEqual(result, []string{"shop.example.co.uk"}) {
+		t.Errorf("Forced base domain should not be split, got %s", result)
+	}
+
+	if result := cfg.DomainStructure(true); !reflect.DeepEqual(result, []string{"shop", "example.co.uk"}) {
+		t.Errorf("Ignored forced base domain should be split, got %s", result)
+	}
+}
+
 func TestReverseSlice(t *testing.T) {
 	tables := []struct {
 		input    []string
@@ -43,3 +65,257 @@ func TestReverseSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestSiteConfig_TemplateData(t *testing.T) {
+	tables := []struct {
+		cfg       SiteConfig
+		addresses []string
+		redirects []Redirect
+	}{
+		{SiteConfig{DomainName: "example.com"}, []string{"example.com"}, nil},
+		{SiteConfig{DomainName: "example.com", Aliases: []string{"example.pl"}, Www: WwwAlias}, []string{"example.com", "example.pl", "www.example.com"}, nil},
+		{SiteConfig{DomainName: "example.com", Www: WwwRedirect}, []string{"example.com"}, []Redirect{{From: "www.example.com", To: "example.com"}}},
+		{SiteConfig{DomainName: "www.example.com", Www: WwwRedirect}, []string{"www.example.com"}, []Redirect{{From: "example.com", To: "www.example.com"}}},
+		{SiteConfig{DomainName: "new.com", RedirectFrom: []string{"old.com"}, Www: WwwRedirect}, []string{"new.com"}, []Redirect{{From: "old.com", To: "new.com"}, {From: "www.new.com", To: "new.com"}}},
+	}
+
+	for _, table := range tables {
+		data := table.cfg.TemplateData()
+
+		if !reflect.DeepEqual(data.Addresses, table.addresses) || !reflect.DeepEqual(data.Redirects, table.redirects) {
+			t.Errorf("Template data of %s built incorrectly, expected %v and %v, got %v and %v", table.cfg.DomainName, table.addresses, table.redirects, data.Addresses, data.Redirects)
+		}
+	}
+}
+
+func TestRenderCaddyfile(t *testing.T) {
+	cfg := SiteConfig{DomainName: "example.com", Aliases: []string{"example.pl"}, Www: WwwRedirect, Port: 8080}
+
+	result, err := renderCaddyfile("test", "{{.SiteAddress}} {\n\treverse_proxy 127.0.0.1:{{.Port}}\n}\n", cfg.TemplateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "example.com, example.pl {\n\treverse_proxy 127.0.0.1:8080\n}\n\nwww.example.com {\n\tredir https://example.com{uri} permanent\n}\n"
+	if string(result) != expected {
+		t.Errorf("Caddyfile rendered incorrectly, expected %q, got %q", expected, string(result))
+	}
+}
+
+func TestSiteConfig_TemplateDataUpstreams(t *testing.T) {
+	tables := []struct {
+		cfg       SiteConfig
+		upstreams string
+	}{
+		{SiteConfig{DomainName: "app.example.com", Port: 9001}, "127.0.0.1:9001"},
+		{SiteConfig{DomainName: "app.example.com", Port: 9001, Upstreams: []string{"10.0.0.2:3000", "10.0.0.3:3000"}}, "10.0.0.2:3000 10.0.0.3:3000"},
+	}
+
+	for _, table := range tables {
+		if upstreams := table.cfg.TemplateData().UpstreamList(); upstreams != table.upstreams {
+			t.Errorf("Upstreams of %s built incorrectly, expected %s, got %s", table.cfg.DomainName, table.upstreams, upstreams)
+		}
+	}
+}
+
+func TestSiteConfig_RenderConfigInheritance(t *testing.T) {
+	defer func() { _ = utils.RegisterProgramTypes(nil) }()
+	if err := utils.RegisterProgramTypes([]utils.ProgramTypeDefinition{{Name: "laravel", Base: "php"}, {Name: "loop"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	envConfig := utils.EnvironmentConfig{CaddySites: t.TempDir()}
+	sitesAll := path.Join(envConfig.CaddySites, "sites-all")
+
+	files := map[string]string{
+		"template_php":            "$SITE_ADDRESS {\n\troot * {{block \"root\" .}}$FILES_ROOT/public_html{{end}}\n\t{{template \"security\" .}}\n}\n",
+		"template_laravel":        "# extends: template_php\n{{define \"root\"}}{{.FilesRoot}}/public{{end}}",
+		"template_loop":           "# extends: template_loop\n",
+		"snippets/security.caddy": "header -Server",
+	}
+
+	for name, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(sitesAll, name)), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(sitesAll, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tables := []struct {
+		programType utils.ProgramType
+		expected    string
+	}{
+		{utils.ProgramTypePhp, "example.com {\n\troot * /srv/example.com/public_html\n\theader -Server\n}\n"},
+		{"LARAVEL", "example.com {\n\troot * /srv/example.com/public\n\theader -Server\n}\n"},
+	}
+
+	for _, table := range tables {
+		cfg := SiteConfig{Type: table.programType, DomainName: "example.com", filesRoot: "/srv/example.com"}
+
+		result, err := cfg.RenderConfig(envConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != table.expected {
+			t.Errorf("Caddyfile of %s rendered incorrectly, expected %q, got %q", table.programType, table.expected, string(result))
+		}
+	}
+
+	cfg := SiteConfig{Type: "LOOP", DomainName: "example.com"}
+	if _, err := cfg.RenderConfig(envConfig); err == nil {
+		t.Errorf("Template extending itself should fail")
+	}
+}
+
+func TestSiteConfig_RenderConfigPlaceholders(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{CaddySites: t.TempDir()}
+	sitesAll := path.Join(envConfig.CaddySites, "sites-all")
+
+	if err := os.MkdirAll(sitesAll, 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "$SITE_ADDRESS {\n\troot * $FILES_ROOT\n\theader X-Title \"$TITLE\"\n\theader X-Version {{.Vars.version}}\n}\n"
+	if err := ioutil.WriteFile(path.Join(sitesAll, "template_html"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values are data, template actions inside them are never executed
+	cfg := SiteConfig{Type: utils.ProgramTypeHtml, DomainName: "example.com", filesRoot: "/srv/example.com", Vars: map[string]string{"title": "{{.FilesRoot}} $FILES_ROOT", "version": "{{2}}"}}
+
+	result, err := cfg.RenderConfig(envConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "example.com {\n\troot * /srv/example.com\n\theader X-Title \"{{.FilesRoot}} $FILES_ROOT\"\n\theader X-Version {{2}}\n}\n"
+	if string(result) != expected {
+		t.Errorf("Caddyfile rendered incorrectly, expected %q, got %q", expected, string(result))
+	}
+}
+
+func TestSiteConfig_OverlayFileStructure(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{ServerFiles: t.TempDir()}
+	templatePath := path.Join(envConfig.ServerFiles, "templates", "php")
+	filesRoot := path.Join(envConfig.ServerFiles, "example.com")
+
+	files := map[string]string{
+		path.Join(templatePath, "public_html", "index.html"): "<h1>${DOM}</h1>",
+		path.Join(templatePath, "public_html", "index.php"):  "<?php echo 'hi';",
+		path.Join(templatePath, "logs", ".keep"):             "",
+		path.Join(filesRoot, "public_html", "index.html"):    "<h1>My own site</h1>",
+	}
+
+	for name, content := range files {
+		if err := os.MkdirAll(path.Dir(name), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := SiteConfig{Type: utils.ProgramTypePhp, DomainName: "example.com", filesRoot: filesRoot}
+
+	created, err := cfg.OverlayFileStructure(envConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{path.Join(filesRoot, "logs"), path.Join(filesRoot, "logs", ".keep"), path.Join(filesRoot, "public_html", "index.php")}
+	if !reflect.DeepEqual(created, expected) {
+		t.Errorf("Wrong files copied, expected %v, got %v", expected, created)
+	}
+
+	if index, _ := ioutil.ReadFile(path.Join(filesRoot, "public_html", "index.html")); string(index) != "<h1>My own site</h1>" {
+		t.Errorf("Existing index was overwritten, got %s", index)
+	}
+}
+
+func TestSiteConfig_MoveFileStructure(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{ServerFiles: t.TempDir()}
+	site := SiteConfig{DomainName: "old.com", Aliases: []string{"new.com", "old.org"}, PhpSocket: "/run/php/old.com.sock"}
+
+	var err error
+	if site.filesRoot, err = site.DomainRootPath(envConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(path.Join(site.filesRoot, "public_html"), 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := site.Renamed(envConfig, "shop.new.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if renamed.filesRoot != path.Join(envConfig.ServerFiles, "new.com", "domains", "shop.new.com") || len(renamed.PhpSocket) > 0 || !reflect.DeepEqual(renamed.Aliases, site.Aliases) {
+		t.Errorf("Site renamed incorrectly, got %+v", renamed)
+	}
+
+	if renamed, _ = site.Renamed(envConfig, "new.com", ""); !reflect.DeepEqual(renamed.Aliases, []string{"old.org"}) {
+		t.Errorf("New domain should not stay an alias, got %v", renamed.Aliases)
+	}
+
+	if ok, err := site.MoveFileStructure(envConfig, renamed); !ok {
+		t.Fatal(err)
+	}
+
+	if !directoryExists(path.Join(renamed.filesRoot, "public_html")) || directoryExists(site.filesRoot) {
+		t.Errorf("Files were not moved from %s to %s", site.filesRoot, renamed.filesRoot)
+	}
+
+	nested, _ := renamed.Renamed(envConfig, "shop.new.com", "")
+	if ok, _ := renamed.MoveFileStructure(envConfig, nested); ok {
+		t.Errorf("Files root should not be moved inside itself")
+	}
+}
+
+func TestSiteConfig_WriteDatabaseInfo(t *testing.T) {
+	cfg := SiteConfig{DomainName: "example.com", filesRoot: t.TempDir()}
+
+	// Files written by older versions were readable by everyone
+	if err := ioutil.WriteFile(cfg.DatabaseInfoPath(), []byte("old"), 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := cfg.WriteDatabaseInfo("127.0.0.1", 3306, "example", "example", "secret", "localhost"); !ok {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(cfg.DatabaseInfoPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != utils.SiteFileMode {
+		t.Errorf("Wrong mode of database info, expected %s, got %s", utils.SiteFileMode, info.Mode().Perm())
+	}
+}
+
+func TestSiteConfig_ChangeType(t *testing.T) {
+	defer func() { _ = utils.RegisterProgramTypes(nil) }()
+	if err := utils.RegisterProgramTypes([]utils.ProgramTypeDefinition{
+		{Name: "laravel", Base: "php", Vars: map[string]string{"public": "public", "php": "8.1"}},
+		{Name: "symfony", Base: "php", Vars: map[string]string{"public": "public", "php": "8.2"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := SiteConfig{Type: "LARAVEL", DomainName: "example.com", Vars: map[string]string{"public": "web", "php": "8.1", "title": "Shop"}}
+
+	cfg.ChangeType("SYMFONY")
+	if err := cfg.ApplyTypeDefaults(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Own values are kept, defaults of the previous type are replaced
+	expected := map[string]string{"public": "web", "php": "8.2", "title": "Shop"}
+	if cfg.Type != "SYMFONY" || !reflect.DeepEqual(cfg.Vars, expected) {
+		t.Errorf("Type changed incorrectly, expected %v, got %s with %v", expected, cfg.Type, cfg.Vars)
+	}
+}
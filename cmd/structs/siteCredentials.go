@@ -0,0 +1,71 @@
+package structs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+)
+
+// CredentialFiles are searched for database credentials of the site, relative to its files root.
+var CredentialFiles = []string{".env", "wp-config.php", "public_html/.env", "public_html/wp-config.php"}
+
+// credentialSettings are the settings holding database name, user and password in each kind of credential file,
+// as a pattern matching the setting with a given name and value.
+var credentialSettings = map[string]struct {
+	pattern              string
+	name, user, password string
+}{
+	".env":          {`(?m)^(\s*%s\s*=\s*['"]?)%s(['"]?\s*)$`, "DB_DATABASE", "DB_USERNAME", "DB_PASSWORD"},
+	"wp-config.php": {`(define\(\s*['"]%s['"]\s*,\s*['"])%s(['"]\s*\))`, "DB_NAME", "DB_USER", "DB_PASSWORD"},
+}
+
+// RewriteDatabaseCredentials replaces database name, user and password of from with the ones of to
+// in credential files of the site. Only the database settings are changed, and only if they hold values of from.
+// Returns paths of changed files.
+func (cfg SiteConfig) RewriteDatabaseCredentials(from, to DatabaseRecord) ([]string, error) {
+	var changed []string
+	for _, name := range CredentialFiles {
+		filePath := path.Join(cfg.filesRoot, name)
+		settings := credentialSettings[path.Base(name)]
+
+		content, err := ioutil.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return changed, err
+		}
+
+		rewritten := string(content)
+		for _, replacement := range [][3]string{{settings.password, from.Password, to.Password}, {settings.user, from.User, to.User}, {settings.name, from.Name, to.Name}} {
+			if len(replacement[1]) == 0 || replacement[1] == replacement[2] {
+				continue
+			}
+
+			setting := regexp.MustCompile(fmt.Sprintf(settings.pattern, regexp.QuoteMeta(replacement[0]), regexp.QuoteMeta(replacement[1])))
+			rewritten = setting.ReplaceAllStringFunc(rewritten, func(match string) string {
+				groups := setting.FindStringSubmatch(match)
+
+				return groups[1] + replacement[2] + groups[2]
+			})
+		}
+
+		if rewritten == string(content) {
+			continue
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return changed, err
+		}
+
+		if err = ioutil.WriteFile(filePath, []byte(rewritten), info.Mode()); err != nil {
+			return changed, err
+		}
+
+		changed = append(changed, filePath)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,55 @@
+package structs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSiteConfig_RewriteDatabaseCredentials(t *testing.T) {
+	site := SiteConfig{DomainName: "staging.example.com", filesRoot: t.TempDir()}
+
+	files := map[string][2]string{
+		".env": {
+			"APP_NAME=example\nDB_DATABASE=example\nDB_USERNAME=example\nDB_PASSWORD=s3cr$t\n",
+			"APP_NAME=example\nDB_DATABASE=staging_example\nDB_USERNAME=staging_example\nDB_PASSWORD=n3w$\n",
+		},
+		"public_html/.env": {
+			"DB_DATABASE=\"other\"\nDB_USERNAME=\"example\"\nMAIL_USERNAME=example\n",
+			"DB_DATABASE=\"other\"\nDB_USERNAME=\"staging_example\"\nMAIL_USERNAME=example\n",
+		},
+		"public_html/wp-config.php": {
+			"define( 'DB_NAME', 'example' );\ndefine( 'DB_USER', 'example' );\ndefine( 'DB_PASSWORD', \"s3cr$t\" );\ndefine( 'AUTH_KEY', 'example' );\n$table_prefix = 'example_';\n",
+			"define( 'DB_NAME', 'staging_example' );\ndefine( 'DB_USER', 'staging_example' );\ndefine( 'DB_PASSWORD', \"n3w$\" );\ndefine( 'AUTH_KEY', 'example' );\n$table_prefix = 'example_';\n",
+		},
+	}
+
+	for name, content := range files {
+		filePath := path.Join(site.filesRoot, name)
+		_ = os.MkdirAll(path.Dir(filePath), 0775)
+		if err := ioutil.WriteFile(filePath, []byte(content[0]), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := DatabaseRecord{Name: "example", User: "example", Password: "s3cr$t"}
+	to := DatabaseRecord{Name: "staging_example", User: "staging_example", Password: "n3w$"}
+
+	changed, err := site.RewriteDatabaseCredentials(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{path.Join(site.filesRoot, ".env"), path.Join(site.filesRoot, "public_html/.env"), path.Join(site.filesRoot, "public_html/wp-config.php")}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Wrong files changed, expected %v, got %v", expected, changed)
+	}
+
+	for name, content := range files {
+		if result, _ := ioutil.ReadFile(path.Join(site.filesRoot, name)); string(result) != content[1] {
+			t.Errorf("Credentials in %s rewritten incorrectly, expected %q, got %q", name, content[1], result)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package structs
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type DatabaseRecord struct {
+	Type     utils.DatabaseType `json:"type"`
+	Host     string             `json:"host"`
+	Port     int                `json:"port"`
+	Name     string             `json:"name"`
+	User     string             `json:"user"`
+	Password string             `json:"password"`
+	UserHost string             `json:"userHost"`
+}
+
+type SiteRecord struct {
+	DomainName   string            `json:"domainName"`
+	DisplayName  string            `json:"displayName,omitempty"`
+	Type         utils.ProgramType `json:"type"`
+	Port         int               `json:"port,omitempty"`
+	Upstreams    []string          `json:"upstreams,omitempty"`
+	LbPolicy     string            `json:"lbPolicy,omitempty"`
+	HealthPath   string            `json:"healthPath,omitempty"`
+	HealthEvery  string            `json:"healthInterval,omitempty"`
+	ForceBase    bool              `json:"forceBase,omitempty"`
+	Vars         map[string]string `json:"vars,omitempty"`
+	Aliases      []string          `json:"aliases,omitempty"`
+	Www          string            `json:"www,omitempty"`
+	RedirectFrom []string          `json:"redirectFrom,omitempty"`
+	BasicAuth    []BasicAuthUser   `json:"basicAuth,omitempty"`
+	Tls          *SiteTls          `json:"tls,omitempty"`
+	Preset       string            `json:"preset,omitempty"`
+	SystemUser   string            `json:"systemUser,omitempty"`
+	PhpSocket    string            `json:"phpSocket,omitempty"`
+	Service      string            `json:"service,omitempty"`
+	Caddyfile    string            `json:"caddyfile"`
+	FilesRoot    string            `json:"filesRoot"`
+	Database     *DatabaseRecord   `json:"database,omitempty"`
+}
+
+// SiteRegistry keeps track of every site created by the tool, so other commands
+// do not have to guess paths and credentials.
+type SiteRegistry struct {
+	Sites map[string]SiteRecord `json:"sites"`
+
+	path string
+}
+
+var ErrSiteNotRegistered = errors.New("site not registered")
+
+func LoadRegistry(envConfig utils.EnvironmentConfig) (*SiteRegistry, error) {
+	registry := &SiteRegistry{
+		Sites: map[string]SiteRecord{},
+		path:  envConfig.Registry,
+	}
+
+	content, err := ioutil.ReadFile(envConfig.Registry)
+	if os.IsNotExist(err) {
+		return registry, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, registry); err != nil {
+		return nil, err
+	}
+
+	if registry.Sites == nil {
+		registry.Sites = map[string]SiteRecord{}
+	}
+
+	return registry, nil
+}
+
+func (registry SiteRegistry) Get(domain string) (SiteRecord, error) {
+	record, ok := registry.Sites[domain]
+	if !ok {
+		return SiteRecord{}, ErrSiteNotRegistered
+	}
+
+	return record, nil
+}
+
+func (registry *SiteRegistry) Put(record SiteRecord) {
+	registry.Sites[record.DomainName] = record
+}
+
+func (registry *SiteRegistry) Remove(domain string) {
+	delete(registry.Sites, domain)
+}
+
+// Domains returns registered domain names in alphabetical order.
+func (registry SiteRegistry) Domains() []string {
+	domains := make([]string, 0, len(registry.Sites))
+	for domain := range registry.Sites {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+
+	return domains
+}
+
+// Owner returns the domain of the registered site serving name, as its domain, its www counterpart,
+// one of its aliases or a former domain redirected to it.
+func (registry SiteRegistry) Owner(name string) (string, bool) {
+	for domain, record := range registry.Sites {
+		if domain == name {
+			return domain, true
+		}
+
+		if counterpart := (SiteConfig{DomainName: domain, Www: record.Www}).WwwCounterpart(); counterpart == name {
+			return domain, true
+		}
+
+		for _, alias := range record.Aliases {
+			if alias == name {
+				return domain, true
+			}
+		}
+
+		for _, former := range record.RedirectFrom {
+			if former == name {
+				return domain, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// SitesInside returns domains of registered sites with files root below the directory.
+func (registry SiteRegistry) SitesInside(directory string) []string {
+	var domains []string
+	for _, domain := range registry.Domains() {
+		if isInside(registry.Sites[domain].FilesRoot, directory) {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains
+}
+
+// SiteAround returns the domain of the registered site with files root closest above the directory, if there is one.
+func (registry SiteRegistry) SiteAround(directory string) (string, bool) {
+	around := ""
+	for _, domain := range registry.Domains() {
+		filesRoot := registry.Sites[domain].FilesRoot
+		if isInside(directory, filesRoot) && (around == "" || isInside(filesRoot, registry.Sites[around].FilesRoot)) {
+			around = domain
+		}
+	}
+
+	return around, around != ""
+}
+
+// Unregistered returns domains of Caddyfiles in sites-all which no registered site owns, i.e. created
+// by hand or by versions of the tool without the registry, in alphabetical order.
+func (registry SiteRegistry) Unregistered(envConfig utils.EnvironmentConfig) ([]string, error) {
+	sitesAllPath := path.Join(envConfig.CaddySites, "sites-all")
+
+	entries, err := ioutil.ReadDir(sitesAllPath)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := map[string]bool{}
+	for _, record := range registry.Sites {
+		owned[path.Clean(record.Caddyfile)] = true
+	}
+
+	var domains []string
+	for _, entry := range entries {
+		domain := strings.TrimSuffix(entry.Name(), ".Caddyfile")
+		if entry.IsDir() || domain == entry.Name() || owned[path.Join(sitesAllPath, entry.Name())] {
+			continue
+		}
+
+		if _, ok := registry.Sites[domain]; !ok {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains, nil
+}
+
+func (registry SiteRegistry) Save() (bool, error) {
+	content, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	// Registry contains database passwords, so keep it private
+	err = ioutil.WriteFile(registry.path, content, 0600)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (cfg SiteConfig) Record() SiteRecord {
+	return SiteRecord{
+		DomainName:   cfg.DomainName,
+		DisplayName:  cfg.DisplayName,
+		Type:         cfg.Type,
+		Port:         cfg.Port,
+		Upstreams:    cfg.Upstreams,
+		LbPolicy:     cfg.LbPolicy,
+		HealthPath:   cfg.HealthPath,
+		HealthEvery:  cfg.HealthEvery,
+		ForceBase:    cfg.ForceBase,
+		Vars:         cfg.Vars,
+		Aliases:      cfg.Aliases,
+		Www:          cfg.Www,
+		RedirectFrom: cfg.RedirectFrom,
+		BasicAuth:    cfg.BasicAuth,
+		Tls:          cfg.Tls.record(),
+		Preset:       cfg.Preset,
+		SystemUser:   cfg.SystemUser,
+		PhpSocket:    cfg.PhpSocket,
+		Service:      cfg.Service,
+		Caddyfile:    cfg.caddyfile,
+		FilesRoot:    cfg.filesRoot,
+	}
+}
+
+func SiteConfigFromRecord(record SiteRecord) SiteConfig {
+	return SiteConfig{
+		Type:         record.Type,
+		DomainName:   record.DomainName,
+		DisplayName:  record.DisplayName,
+		Port:         record.Port,
+		Upstreams:    record.Upstreams,
+		LbPolicy:     record.LbPolicy,
+		HealthPath:   record.HealthPath,
+		HealthEvery:  record.HealthEvery,
+		ForceBase:    record.ForceBase,
+		Vars:         record.Vars,
+		Aliases:      record.Aliases,
+		Www:          record.Www,
+		RedirectFrom: record.RedirectFrom,
+		BasicAuth:    record.BasicAuth,
+		Tls:          record.Tls.config(),
+		Preset:       record.Preset,
+		SystemUser:   record.SystemUser,
+		PhpSocket:    record.PhpSocket,
+		Service:      record.Service,
+		caddyfile:    record.Caddyfile,
+		filesRoot:    record.FilesRoot,
+	}
+}
@@ -0,0 +1,91 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSiteRegistry_Unregistered(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{CaddySites: t.TempDir()}
+	sitesAllPath := path.Join(envConfig.CaddySites, "sites-all")
+
+	if err := os.MkdirAll(path.Join(sitesAllPath, "snippets"), 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"example.com.Caddyfile", "manual.example.com.Caddyfile", "old.example.org.Caddyfile", "template_html"} {
+		if err := ioutil.WriteFile(path.Join(sitesAllPath, name), []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := SiteRegistry{Sites: map[string]SiteRecord{
+		"example.com": {DomainName: "example.com", Caddyfile: path.Join(sitesAllPath, "example.com.Caddyfile")},
+		"missing.com": {DomainName: "missing.com", Caddyfile: path.Join(sitesAllPath, "missing.com.Caddyfile")},
+	}}
+
+	expected := []string{"manual.example.com", "old.example.org"}
+
+	domains, err := registry.Unregistered(envConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Unregistered Caddyfiles found incorrectly, expected %s, got %s", expected, domains)
+	}
+}
+
+func TestSiteRegistry_SiteAround(t *testing.T) {
+	registry := SiteRegistry{Sites: map[string]SiteRecord{
+		"example.com":     {DomainName: "example.com", FilesRoot: "/srv/example.com"},
+		"api.example.com": {DomainName: "api.example.com", FilesRoot: "/srv/example.com/api.example.com"},
+		"example.org":     {DomainName: "example.org", FilesRoot: "/srv/example.org"},
+	}}
+
+	tables := []struct {
+		directory string
+		around    string
+	}{
+		{"/srv/example.com/api.example.com/v2.api.example.com", "api.example.com"},
+		{"/srv/example.com/blog.example.com", "example.com"},
+		{"/srv/example.com", ""},
+		{"/srv/example.net", ""},
+	}
+
+	for _, table := range tables {
+		if around, _ := registry.SiteAround(table.directory); around != table.around {
+			t.Errorf("Wrong site around %s, expected %s, got %s", table.directory, table.around, around)
+		}
+	}
+}
+
+func TestSiteRegistry_Owner(t *testing.T) {
+	registry := SiteRegistry{Sites: map[string]SiteRecord{
+		"example.com":     {DomainName: "example.com", Www: WwwRedirect, Aliases: []string{"example.net"}},
+		"www.example.org": {DomainName: "www.example.org", Www: WwwAlias},
+		"example.io":      {DomainName: "example.io", Www: WwwNone, RedirectFrom: []string{"old.example.io"}},
+	}}
+
+	tables := []struct {
+		name  string
+		owner string
+	}{
+		{"example.com", "example.com"},
+		{"www.example.com", "example.com"},
+		{"example.net", "example.com"},
+		{"example.org", "www.example.org"},
+		{"www.example.io", ""},
+		{"old.example.io", "example.io"},
+	}
+
+	for _, table := range tables {
+		if owner, _ := registry.Owner(table.name); owner != table.owner {
+			t.Errorf("Wrong owner of %s, expected %s, got %s", table.name, table.owner, owner)
+		}
+	}
+}
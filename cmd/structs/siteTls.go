@@ -0,0 +1,134 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	TlsInternal = "internal" // Certificate from Caddy's internal CA, i.e. for intranet sites
+	TlsAcme     = "acme"     // Certificate from an ACME CA, Let's Encrypt by default
+	TlsCustom   = "custom"   // Certificate and key provided by the customer
+)
+
+// SiteTls describes how the site gets its certificate. Empty mode leaves it to the template.
+type SiteTls struct {
+	Mode  string `json:"mode"`
+	Cert  string `json:"cert,omitempty"`  // Certificate file, for custom mode
+	Key   string `json:"key,omitempty"`   // Key file, for custom mode
+	Email string `json:"email,omitempty"` // ACME account email
+	Ca    string `json:"ca,omitempty"`    // ACME directory URL
+	Dns   string `json:"dns,omitempty"`   // DNS provider (with its arguments) solving the DNS challenge, i.e. cloudflare {env.CF_API_TOKEN}
+}
+
+var ErrInvalidTlsMode = errors.New("tls mode should be 'internal', 'acme' or 'custom'")
+
+// Validate checks that options fit the mode.
+func (tls SiteTls) Validate() error {
+	switch tls.Mode {
+	case "":
+		if tls != (SiteTls{}) {
+			return errors.New("tls options require a tls mode")
+		}
+	case TlsInternal:
+		if len(tls.Cert) > 0 || len(tls.Key) > 0 || len(tls.Email) > 0 || len(tls.Ca) > 0 || len(tls.Dns) > 0 {
+			return errors.New("internal tls mode takes no other options")
+		}
+	case TlsCustom:
+		if len(tls.Cert) == 0 || len(tls.Key) == 0 {
+			return errors.New("custom tls mode requires certificate and key files")
+		}
+
+		if !filepath.IsAbs(tls.Cert) || !filepath.IsAbs(tls.Key) || strings.ContainsAny(tls.Cert+tls.Key, "\n\r") {
+			return errors.New("certificate and key files should be given as absolute paths")
+		}
+
+		if !fileExists(tls.Cert) || !fileExists(tls.Key) {
+			return fmt.Errorf("certificate %s or key %s does not exist", tls.Cert, tls.Key)
+		}
+
+		if len(tls.Email) > 0 || len(tls.Ca) > 0 || len(tls.Dns) > 0 {
+			return errors.New("custom tls mode takes no ACME options")
+		}
+	case TlsAcme:
+		if len(tls.Cert) > 0 || len(tls.Key) > 0 {
+			return errors.New("acme tls mode takes no certificate and key files")
+		}
+
+		if len(tls.Email) > 0 && (!strings.Contains(tls.Email, "@") || strings.ContainsAny(tls.Email, " \t{}")) {
+			return fmt.Errorf("%s is not correct email", tls.Email)
+		}
+
+		if len(tls.Ca) > 0 {
+			if ca, err := url.Parse(tls.Ca); err != nil || ca.Scheme != "https" && ca.Scheme != "http" || len(ca.Host) == 0 {
+				return fmt.Errorf("%s is not correct ACME directory URL", tls.Ca)
+			}
+		}
+
+		if strings.ContainsAny(tls.Dns, "\n\r") {
+			return errors.New("dns provider should be given in a single line")
+		}
+	default:
+		return ErrInvalidTlsMode
+	}
+
+	return nil
+}
+
+// Directive renders the tls directive for the site block. Empty for ACME without options, as it is Caddy's default.
+func (tls SiteTls) Directive(indent string) string {
+	switch tls.Mode {
+	case TlsInternal:
+		return fmt.Sprintf("%stls internal\n", indent)
+	case TlsCustom:
+		return fmt.Sprintf("%stls %s %s\n", indent, caddyfileQuote(tls.Cert), caddyfileQuote(tls.Key))
+	case TlsAcme:
+		var directive strings.Builder
+
+		directive.WriteString(indent + "tls")
+		if len(tls.Email) > 0 {
+			directive.WriteString(" " + tls.Email)
+		}
+
+		if len(tls.Ca) > 0 || len(tls.Dns) > 0 {
+			directive.WriteString(" {\n")
+			if len(tls.Ca) > 0 {
+				_, _ = fmt.Fprintf(&directive, "%s%sca %s\n", indent, indent, tls.Ca)
+			}
+			if len(tls.Dns) > 0 {
+				_, _ = fmt.Fprintf(&directive, "%s%sdns %s\n", indent, indent, tls.Dns)
+			}
+			directive.WriteString(indent + "}")
+		} else if len(tls.Email) == 0 {
+			return ""
+		}
+
+		return directive.String() + "\n"
+	}
+
+	return ""
+}
+
+// caddyfileQuote makes the value a single Caddyfile token, even if it contains spaces or quotes.
+func caddyfileQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func (tls SiteTls) record() *SiteTls {
+	if len(tls.Mode) == 0 {
+		return nil
+	}
+
+	return &tls
+}
+
+func (tls *SiteTls) config() SiteTls {
+	if tls == nil {
+		return SiteTls{}
+	}
+
+	return *tls
+}
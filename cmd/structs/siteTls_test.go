@@ -0,0 +1,94 @@
+package structs
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestSiteTls_Validate(t *testing.T) {
+	cert := path.Join(t.TempDir(), "example.com.pem")
+	if err := ioutil.WriteFile(cert, []byte("certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		tls   SiteTls
+		valid bool
+	}{
+		{SiteTls{}, true},
+		{SiteTls{Email: "admin@example.com"}, false},
+		{SiteTls{Mode: TlsInternal}, true},
+		{SiteTls{Mode: TlsInternal, Cert: cert}, false},
+		{SiteTls{Mode: TlsCustom, Cert: cert, Key: cert}, true},
+		{SiteTls{Mode: TlsCustom, Cert: cert}, false},
+		{SiteTls{Mode: TlsCustom, Cert: cert, Key: "/nonexistent/example.com.key"}, false},
+		{SiteTls{Mode: TlsCustom, Cert: "example.com.pem", Key: "example.com.pem"}, false},
+		{SiteTls{Mode: TlsAcme, Email: "admin@example.com", Ca: "https://acme-staging-v02.api.letsencrypt.org/directory"}, true},
+		{SiteTls{Mode: TlsAcme, Email: "admin"}, false},
+		{SiteTls{Mode: TlsAcme, Ca: "letsencrypt"}, false},
+		{SiteTls{Mode: "letsencrypt"}, false},
+	}
+
+	for _, table := range tables {
+		if err := table.tls.Validate(); (err == nil) != table.valid {
+			t.Errorf("Validity of %+v incorrect, expected %t, got error %v", table.tls, table.valid, err)
+		}
+	}
+}
+
+func TestSiteTls_Directive(t *testing.T) {
+	tables := []struct {
+		tls      SiteTls
+		expected string
+	}{
+		{SiteTls{}, ""},
+		{SiteTls{Mode: TlsInternal}, "\ttls internal\n"},
+		{SiteTls{Mode: TlsCustom, Cert: "/etc/ssl/a.pem", Key: "/etc/ssl/a.key"}, "\ttls \"/etc/ssl/a.pem\" \"/etc/ssl/a.key\"\n"},
+		{SiteTls{Mode: TlsCustom, Cert: "/etc/ssl/my site.pem", Key: "/etc/ssl/\"a\".key"}, "\ttls \"/etc/ssl/my site.pem\" \"/etc/ssl/\\\"a\\\".key\"\n"},
+		{SiteTls{Mode: TlsAcme}, ""},
+		{SiteTls{Mode: TlsAcme, Email: "admin@example.com"}, "\ttls admin@example.com\n"},
+		{
+			SiteTls{Mode: TlsAcme, Ca: "https://ca.example.com/directory", Dns: "cloudflare {env.CF_API_TOKEN}"},
+			"\ttls {\n\t\tca https://ca.example.com/directory\n\t\tdns cloudflare {env.CF_API_TOKEN}\n\t}\n",
+		},
+	}
+
+	for _, table := range tables {
+		if directive := table.tls.Directive("\t"); directive != table.expected {
+			t.Errorf("Directive of %+v rendered incorrectly, expected %q, got %q", table.tls, table.expected, directive)
+		}
+	}
+
+	cfg := SiteConfig{DomainName: "intranet.example.com", Tls: SiteTls{Mode: TlsInternal}, BasicAuth: []BasicAuthUser{{User: "staff", Hash: "$2a$10$hash"}}}
+
+	result, err := renderCaddyfile("test", "{{.SiteAddress}} {\n  root * /srv\n}\n", cfg.TemplateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "intranet.example.com {\n  tls internal\n  basicauth {\n    staff $2a$10$hash\n  }\n  root * /srv\n}\n"
+	if string(result) != expected {
+		t.Errorf("Caddyfile rendered incorrectly, expected %q, got %q", expected, string(result))
+	}
+}
+
+func TestSiteTls_DirectiveInAllSiteBlocks(t *testing.T) {
+	cfg := SiteConfig{DomainName: "example.com", Www: WwwRedirect, RedirectFrom: []string{"old.com"}, Tls: SiteTls{Mode: TlsInternal}, BasicAuth: []BasicAuthUser{{User: "staff", Hash: "$2a$10$hash"}}}
+
+	content := "{\n\temail admin@example.com\n}\n\n(common) {\n\tencode gzip\n}\n\n{{.SiteAddress}} {\n\timport common\n\thandle {\n\t\tfile_server\n\t}\n}\n"
+
+	result, err := renderCaddyfile("test", content, cfg.TemplateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Redirect blocks get the certificate, but not the basic auth of the site
+	expected := "{\n\temail admin@example.com\n}\n\n(common) {\n\tencode gzip\n}\n\n" +
+		"example.com {\n\ttls internal\n\tbasicauth {\n\t\tstaff $2a$10$hash\n\t}\n\timport common\n\thandle {\n\t\tfile_server\n\t}\n}\n" +
+		"\nold.com {\n\ttls internal\n\tredir https://example.com{uri} permanent\n}\n" +
+		"\nwww.example.com {\n\ttls internal\n\tredir https://example.com{uri} permanent\n}\n"
+	if string(result) != expected {
+		t.Errorf("Caddyfile rendered incorrectly, expected %q, got %q", expected, string(result))
+	}
+}
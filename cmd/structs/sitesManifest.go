@@ -0,0 +1,214 @@
+package structs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type SiteManifestDatabase struct {
+	Type     string `yaml:"type"`
+	Host     string `yaml:"host"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	UserHost string `yaml:"userHost"`
+}
+
+type SiteManifestHealth struct {
+	Path     string `yaml:"path"`
+	Interval string `yaml:"interval"`
+}
+
+// SiteManifestEntry is the desired state of a single site, as declared in a sites manifest.
+type SiteManifestEntry struct {
+	Domain    string                `yaml:"domain"`
+	Type      string                `yaml:"type"`
+	Port      int                   `yaml:"port"`
+	ForceBase bool                  `yaml:"forceBase"`
+	Database  *SiteManifestDatabase `yaml:"database"`
+	Vars      map[string]string     `yaml:"vars"`
+	Aliases   []string              `yaml:"aliases"`
+	Www       string                `yaml:"www"`
+	Upstreams []string              `yaml:"upstreams"`
+	LbPolicy  string                `yaml:"lbPolicy"`
+	Health    SiteManifestHealth    `yaml:"healthCheck"`
+
+	DisplayName string `yaml:"-"` // Unicode form of the domain, filled while reading
+}
+
+type SitesManifest struct {
+	Sites []SiteManifestEntry `yaml:"sites"`
+}
+
+func ReadSitesManifest(file string) (SitesManifest, error) {
+	var manifest SitesManifest
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return manifest, err
+	}
+
+	if err = yaml.UnmarshalStrict(content, &manifest); err != nil {
+		return manifest, err
+	}
+
+	seen := map[string]bool{}
+	for i, entry := range manifest.Sites {
+		if len(strings.TrimSpace(entry.Domain)) == 0 {
+			return manifest, fmt.Errorf("site #%d has no domain", i+1)
+		}
+
+		domain, display, err := utils.NormalizeDomain(entry.Domain)
+		if err != nil {
+			return manifest, fmt.Errorf("site #%d: %w", i+1, err)
+		}
+
+		if seen[domain] {
+			return manifest, fmt.Errorf("site %s is declared more than once", domain)
+		}
+
+		if entry.Database != nil && utils.GetDatabaseType(entry.Database.Type) == utils.DatabaseNone {
+			return manifest, fmt.Errorf("site %s has incorrect database type %q, use 'mysql' or 'mongo'", domain, entry.Database.Type)
+		}
+
+		if len(entry.Type) > 0 {
+			if _, err = utils.GetProgramType(entry.Type); err != nil {
+				return manifest, fmt.Errorf("site %s: %w", domain, err)
+			}
+		}
+
+		if !ValidWwwMode(entry.Www) {
+			return manifest, fmt.Errorf("site %s has incorrect www mode %q, use 'redirect', 'alias' or 'none'", domain, entry.Www)
+		}
+
+		for j, alias := range entry.Aliases {
+			if manifest.Sites[i].Aliases[j], _, err = utils.NormalizeDomain(alias); err != nil {
+				return manifest, fmt.Errorf("site %s, alias %s: %w", domain, alias, err)
+			}
+		}
+
+		for j, upstream := range entry.Upstreams {
+			if manifest.Sites[i].Upstreams[j], err = utils.NormalizeUpstream(upstream); err != nil {
+				return manifest, fmt.Errorf("site %s: %w", domain, err)
+			}
+		}
+
+		if !utils.ValidLbPolicy(entry.LbPolicy) {
+			return manifest, fmt.Errorf("site %s has unknown load balancing policy %q", domain, entry.LbPolicy)
+		}
+
+		if !utils.ValidHealthInterval(entry.Health.Interval) {
+			return manifest, fmt.Errorf("site %s has incorrect health check interval %q, use a duration like 10s", domain, entry.Health.Interval)
+		}
+
+		seen[domain] = true
+		manifest.Sites[i].Domain = domain
+		manifest.Sites[i].DisplayName = display
+	}
+
+	return manifest, nil
+}
+
+func (entry SiteManifestEntry) SiteConfig() SiteConfig {
+	cfg := SiteConfig{
+		Type:        utils.ProgramTypePhp,
+		DomainName:  entry.Domain,
+		DisplayName: entry.DisplayName,
+		ForceBase:   entry.ForceBase,
+		Vars:        entry.Vars,
+		Aliases:     entry.Aliases,
+		Www:         entry.Www,
+	}
+
+	// Types are checked while reading the manifest
+	if programType, err := utils.GetProgramType(entry.Type); err == nil {
+		cfg.Type = programType
+	}
+
+	if cfg.Type.Base() == utils.ProgramTypeApp {
+		cfg.Port = entry.Port
+		cfg.Upstreams = entry.Upstreams
+		cfg.LbPolicy = entry.LbPolicy
+		cfg.HealthPath = entry.Health.Path
+		cfg.HealthEvery = entry.Health.Interval
+	}
+
+	return cfg
+}
+
+// ReadSitesCsv reads sites from a CSV file with domain, type, port and db-type columns.
+// Only the domain is required, and the header row is optional.
+func ReadSitesCsv(file string) ([]SiteManifestEntry, error) {
+	content, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func(content *os.File) {
+		_ = content.Close()
+	}(content)
+
+	reader := csv.NewReader(content)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SiteManifestEntry
+	seen := map[string]bool{}
+
+	for i, row := range rows {
+		if i == 0 && strings.EqualFold(row[0], "domain") {
+			continue
+		}
+
+		if len(strings.TrimSpace(row[0])) == 0 {
+			return nil, fmt.Errorf("line %d has no domain", i+1)
+		}
+
+		domain, display, err := utils.NormalizeDomain(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		entry := SiteManifestEntry{Domain: domain, DisplayName: display}
+
+		if seen[entry.Domain] {
+			return nil, fmt.Errorf("site %s is listed more than once", entry.Domain)
+		}
+		seen[entry.Domain] = true
+
+		if len(row) > 1 && len(row[1]) > 0 {
+			if _, err = utils.GetProgramType(row[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+
+			entry.Type = row[1]
+		}
+
+		if len(row) > 2 && len(row[2]) > 0 {
+			if entry.Port, err = strconv.Atoi(row[2]); err != nil {
+				return nil, fmt.Errorf("line %d has incorrect port %q", i+1, row[2])
+			}
+		}
+
+		if len(row) > 3 && len(row[3]) > 0 {
+			if utils.GetDatabaseType(row[3]) == utils.DatabaseNone {
+				return nil, fmt.Errorf("line %d has incorrect database type %q, use 'mysql' or 'mongo'", i+1, row[3])
+			}
+
+			entry.Database = &SiteManifestDatabase{Type: row[3]}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
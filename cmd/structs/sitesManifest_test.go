@@ -0,0 +1,80 @@
+package structs
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestReadSitesManifest(t *testing.T) {
+	tables := []struct {
+		content string
+		valid   bool
+	}{
+		{"sites:\n  - domain: Example.com\n    type: php\n  - domain: app.example.com\n    type: app\n    port: 8081\n", true},
+		{"sites:\n  - domain: example.com\n  - domain: EXAMPLE.com\n", false},
+		{"sites:\n  - type: php\n", false},
+		{"sites:\n  - domain: example.com\n    database:\n      type: postgres\n", false},
+		{"sites:\n  - domain: example.com\n    unknown: true\n", false},
+	}
+
+	for _, table := range tables {
+		file := path.Join(t.TempDir(), "sites.yaml")
+		if err := ioutil.WriteFile(file, []byte(table.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		manifest, err := ReadSitesManifest(file)
+
+		if table.valid && err != nil {
+			t.Errorf("Manifest %q should be valid, got %s", table.content, err)
+		} else if !table.valid && err == nil {
+			t.Errorf("Manifest %q should be invalid", table.content)
+		}
+
+		if table.valid && manifest.Sites[0].Domain != "example.com" {
+			t.Errorf("Domain should be lowercased, got %s", manifest.Sites[0].Domain)
+		}
+	}
+}
+
+func TestReadSitesCsv(t *testing.T) {
+	tables := []struct {
+		content  string
+		expected []SiteManifestEntry
+		valid    bool
+	}{
+		{"domain,type,port,db-type\nExample.com,html\napp.example.com, application, 8081\nshop.example.com,php,,mysql\n", []SiteManifestEntry{
+			{Domain: "example.com", DisplayName: "example.com", Type: "html"},
+			{Domain: "app.example.com", DisplayName: "app.example.com", Type: "application", Port: 8081},
+			{Domain: "shop.example.com", DisplayName: "shop.example.com", Type: "php", Database: &SiteManifestDatabase{Type: "mysql"}},
+		}, true},
+		{"żółw.pl\n", []SiteManifestEntry{{Domain: "xn--w-uga1v8h.pl", DisplayName: "żółw.pl"}}, true},
+		{"example.com\nEXAMPLE.com\n", nil, false},
+		{",html\n", nil, false},
+		{"example.com,unknown\n", nil, false},
+		{"example.com,application,port\n", nil, false},
+		{"example.com,php,,postgres\n", nil, false},
+		{"-example.com\n", nil, false},
+	}
+
+	for _, table := range tables {
+		file := path.Join(t.TempDir(), "sites.csv")
+		if err := ioutil.WriteFile(file, []byte(table.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := ReadSitesCsv(file)
+
+		if table.valid && err != nil {
+			t.Errorf("CSV %q should be valid, got %s", table.content, err)
+		} else if !table.valid && err == nil {
+			t.Errorf("CSV %q should be invalid", table.content)
+		}
+
+		if table.valid && !reflect.DeepEqual(entries, table.expected) {
+			t.Errorf("CSV %q read incorrectly, expected %+v, got %+v", table.content, table.expected, entries)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package structs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// SystemdUnitTemplateName is the unit template inside the application template directory.
+// It is not copied to the files root.
+const SystemdUnitTemplateName = "app.service"
+
+// SystemdSettings configure services generated for application sites.
+type SystemdSettings struct {
+	UnitDirectory string // i.e. /etc/systemd/system
+	User          string // Used when the site has no system user of its own
+	Restart       string // Restart= policy, i.e. on-failure
+}
+
+// SystemdUnitData is available in the unit template.
+type SystemdUnitData struct {
+	Name             string // Unit name, without .service
+	Domain           string
+	DisplayName      string
+	WorkingDirectory string // Files root of the site
+	User             string
+	Port             int
+	Restart          string
+	Vars             map[string]string
+}
+
+// ServiceManager runs systemctl. SystemctlRunner is the real one.
+type ServiceManager interface {
+	DaemonReload() error
+	Enable(unit string) error
+	Start(unit string) error
+	Stop(unit string) error
+	Disable(unit string) error
+	// TryRestart restarts the unit if it is running, leaving a stopped one stopped.
+	TryRestart(unit string) error
+	// IsEnabled tells whether the unit starts on boot.
+	IsEnabled(unit string) bool
+}
+
+// SystemctlRunner calls the configured systemctl binary, which may include arguments (i.e. "systemctl --user").
+type SystemctlRunner struct {
+	Command string
+}
+
+func (runner SystemctlRunner) run(args ...string) error {
+	command := strings.Fields(runner.Command)
+	if len(command) == 0 {
+		command = []string{"systemctl"}
+	}
+
+	out, err := exec.Command(command[0], append(command[1:], args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", runner.Command, strings.Join(args, " "), err, out)
+	}
+
+	return nil
+}
+
+func (runner SystemctlRunner) DaemonReload() error {
+	return runner.run("daemon-reload")
+}
+
+func (runner SystemctlRunner) Enable(unit string) error {
+	return runner.run("enable", unit)
+}
+
+func (runner SystemctlRunner) Start(unit string) error {
+	return runner.run("start", unit)
+}
+
+func (runner SystemctlRunner) Stop(unit string) error {
+	return runner.run("stop", unit)
+}
+
+func (runner SystemctlRunner) Disable(unit string) error {
+	return runner.run("disable", unit)
+}
+
+func (runner SystemctlRunner) TryRestart(unit string) error {
+	return runner.run("try-restart", unit)
+}
+
+func (runner SystemctlRunner) IsEnabled(unit string) bool {
+	return runner.run("is-enabled", "--quiet", unit) == nil
+}
+
+// ServiceName is the systemd unit of the application site.
+func (cfg SiteConfig) ServiceName() string {
+	return cfg.DomainName + ".service"
+}
+
+// RenderSystemdUnit executes the unit template of application sites.
+func (cfg SiteConfig) RenderSystemdUnit(envConfig utils.EnvironmentConfig, settings SystemdSettings) ([]byte, error) {
+	templatePath := path.Join(envConfig.ServerFiles, "templates", cfg.Type.Definition().FilesTemplateName(), SystemdUnitTemplateName)
+
+	if !fileExists(templatePath) {
+		return nil, fs.ErrNotExist
+	}
+
+	content, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := template.New(SystemdUnitTemplateName).Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	data := SystemdUnitData{
+		Name:             strings.TrimSuffix(cfg.ServiceName(), ".service"),
+		Domain:           cfg.DomainName,
+		DisplayName:      cfg.Name(),
+		WorkingDirectory: cfg.filesRoot,
+		User:             cfg.SystemUser,
+		Port:             cfg.Port,
+		Restart:          settings.Restart,
+		Vars:             cfg.Vars,
+	}
+
+	if len(data.User) == 0 {
+		data.User = settings.User
+	}
+
+	if len(data.Restart) == 0 {
+		data.Restart = "on-failure"
+	}
+
+	var out bytes.Buffer
+	if err = parsed.Execute(&out, data); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// RemoveSystemdUnit deletes the unit file of the site, if there is one. The unit should be stopped first.
+func (cfg *SiteConfig) RemoveSystemdUnit(settings SystemdSettings, services ServiceManager) (bool, error) {
+	if len(cfg.Service) == 0 {
+		return false, nil
+	}
+
+	if err := os.Remove(path.Join(settings.UnitDirectory, cfg.Service)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	cfg.Service = ""
+
+	if err := services.DaemonReload(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CreateSystemdUnit installs the site's service unit and reloads systemd, so it knows the unit.
+// An existing unit of the same name is never overwritten, fs.ErrExist is returned instead.
+func (cfg *SiteConfig) CreateSystemdUnit(envConfig utils.EnvironmentConfig, settings SystemdSettings, services ServiceManager) (bool, error) {
+	content, err := cfg.RenderSystemdUnit(envConfig, settings)
+	if err != nil {
+		return false, err
+	}
+
+	file, err := os.OpenFile(path.Join(settings.UnitDirectory, cfg.ServiceName()), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cfg.Service = cfg.ServiceName()
+
+	if err = services.DaemonReload(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateSystemdUnit renders the site's installed unit again, i.e. after its port changed, and reloads systemd.
+// Returns false if the unit did not change, the previous unit is kept on failure. The service itself is not restarted.
+func (cfg SiteConfig) UpdateSystemdUnit(envConfig utils.EnvironmentConfig, settings SystemdSettings, services ServiceManager) (bool, error) {
+	if len(cfg.Service) == 0 {
+		return false, nil
+	}
+
+	content, err := cfg.RenderSystemdUnit(envConfig, settings)
+	if err != nil {
+		return false, err
+	}
+
+	unitPath := path.Join(settings.UnitDirectory, cfg.Service)
+
+	current, err := ioutil.ReadFile(unitPath)
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(current, content) {
+		return false, nil
+	}
+
+	if err = ioutil.WriteFile(unitPath, content, 0644); err != nil {
+		return false, err
+	}
+
+	// The previous unit is put back, as systemd may not know the new one
+	if err = services.DaemonReload(); err != nil {
+		_ = ioutil.WriteFile(unitPath, current, 0644)
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,132 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+type fakeServiceManager struct {
+	calls []string
+}
+
+func (f *fakeServiceManager) DaemonReload() error {
+	f.calls = append(f.calls, "daemon-reload")
+	return nil
+}
+
+func (f *fakeServiceManager) Enable(unit string) error {
+	f.calls = append(f.calls, "enable "+unit)
+	return nil
+}
+
+func (f *fakeServiceManager) Start(unit string) error {
+	f.calls = append(f.calls, "start "+unit)
+	return nil
+}
+
+func (f *fakeServiceManager) Stop(unit string) error {
+	f.calls = append(f.calls, "stop "+unit)
+	return nil
+}
+
+func (f *fakeServiceManager) Disable(unit string) error {
+	f.calls = append(f.calls, "disable "+unit)
+	return nil
+}
+
+func (f *fakeServiceManager) TryRestart(unit string) error {
+	f.calls = append(f.calls, "try-restart "+unit)
+	return nil
+}
+
+func (f *fakeServiceManager) IsEnabled(unit string) bool {
+	return false
+}
+
+func TestSiteConfig_CreateSystemdUnit(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{ServerFiles: t.TempDir()}
+	settings := SystemdSettings{UnitDirectory: t.TempDir(), User: "www-data"}
+
+	templateDir := path.Join(envConfig.ServerFiles, "templates", "application")
+	if err := os.MkdirAll(templateDir, 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	unitTemplate := `[Service]
+User={{.User}}
+WorkingDirectory={{.WorkingDirectory}}
+Environment=PORT={{.Port}}
+ExecStart={{.WorkingDirectory}}/{{.Vars.binary}}
+Restart={{.Restart}}
+`
+	if err := ioutil.WriteFile(path.Join(templateDir, SystemdUnitTemplateName), []byte(unitTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	site := SiteConfig{
+		Type:       utils.ProgramTypeApp,
+		DomainName: "app.example.com",
+		Port:       3000,
+		SystemUser: "app_example",
+		Vars:       map[string]string{"binary": "server"},
+		filesRoot:  "/srv/app.example.com",
+	}
+	services := &fakeServiceManager{}
+
+	if ok, err := site.CreateSystemdUnit(envConfig, settings, services); !ok {
+		t.Fatal(err)
+	}
+
+	if site.Service != "app.example.com.service" {
+		t.Errorf("Wrong service name, expected app.example.com.service, got %s", site.Service)
+	}
+
+	unit, err := ioutil.ReadFile(path.Join(settings.UnitDirectory, site.Service))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"User=app_example", "WorkingDirectory=/srv/app.example.com", "Environment=PORT=3000", "ExecStart=/srv/app.example.com/server", "Restart=on-failure"} {
+		if !strings.Contains(string(unit), line) {
+			t.Errorf("Unit incorrect, expected %s in:\n%s", line, unit)
+		}
+	}
+
+	if len(services.calls) != 1 || services.calls[0] != "daemon-reload" {
+		t.Errorf("Expected only daemon-reload, got %s", services.calls)
+	}
+
+	// Existing units, i.e. of another site or installed by hand, are never overwritten
+	again := site
+	if ok, err := again.CreateSystemdUnit(envConfig, settings, services); ok || !os.IsExist(err) {
+		t.Errorf("Expected existing unit error, got %v", err)
+	}
+
+	// Changing the port renders the installed unit again
+	moved := site
+	moved.Port = 3001
+	if changed, err := moved.UpdateSystemdUnit(envConfig, settings, services); !changed {
+		t.Fatalf("Expected the unit to be rendered again, got %v", err)
+	}
+
+	if unit, err = ioutil.ReadFile(path.Join(settings.UnitDirectory, site.Service)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "Environment=PORT=3001") {
+		t.Errorf("Unit not updated, expected Environment=PORT=3001 in:\n%s", unit)
+	}
+
+	if changed, err := moved.UpdateSystemdUnit(envConfig, settings, services); changed || err != nil {
+		t.Errorf("Expected unchanged unit, got %v (%v)", changed, err)
+	}
+
+	// Sites of types without unit template get no unit
+	html := SiteConfig{Type: utils.ProgramTypeHtml, DomainName: "example.com"}
+	if ok, err := html.CreateSystemdUnit(envConfig, settings, services); ok || !os.IsNotExist(err) {
+		t.Errorf("Expected missing template error, got %v", err)
+	}
+}
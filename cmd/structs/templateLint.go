@@ -0,0 +1,109 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Placeholders left after rendering. Caddy's own {$ENV} placeholders are fine.
+var leftoverPlaceholderRegex = regexp.MustCompile(`(?:^|[^{])(\$[A-Z][A-Z0-9_]*)`)
+
+// SampleSiteConfig is a site of the type, used to render templates without creating anything.
+func SampleSiteConfig(programType utils.ProgramType) SiteConfig {
+	cfg := SiteConfig{
+		Type:        programType,
+		DomainName:  "example.com",
+		DisplayName: "example.com",
+		Port:        8080,
+		Aliases:     []string{"example.org"},
+		Www:         WwwRedirect,
+		SystemUser:  "example",
+		PhpSocket:   "/run/php/example.com.sock",
+		Vars:        map[string]string{},
+		filesRoot:   "/srv/example.com",
+	}
+
+	for _, name := range programType.Definition().RequiredVars {
+		cfg.Vars[name] = "sample-" + name
+	}
+
+	_ = cfg.ApplyTypeDefaults()
+
+	return cfg
+}
+
+// CaddyfileTemplatePath returns where the Caddyfile template of the type is expected.
+func CaddyfileTemplatePath(envConfig utils.EnvironmentConfig, programType utils.ProgramType) string {
+	return path.Join(envConfig.CaddySites, "sites-all", programType.Definition().CaddyfileTemplateName())
+}
+
+// FilesTemplatePath returns where the files template of the type is expected.
+func FilesTemplatePath(envConfig utils.EnvironmentConfig, programType utils.ProgramType) string {
+	return path.Join(envConfig.ServerFiles, "templates", programType.Definition().FilesTemplateName())
+}
+
+// LintTemplates renders both templates of the type with sample data and returns found problems.
+// Caddyfile is also checked with caddy adapt, if caddy is installed.
+func LintTemplates(envConfig utils.EnvironmentConfig, programType utils.ProgramType) []string {
+	var problems []string
+	site := SampleSiteConfig(programType)
+
+	rendered, err := site.RenderConfig(envConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		problems = append(problems, fmt.Sprintf("Caddyfile template %s does not exist", CaddyfileTemplatePath(envConfig, programType)))
+	} else if err != nil {
+		problems = append(problems, fmt.Sprintf("Caddyfile template does not render: %s", err.Error()))
+	} else {
+		for _, match := range leftoverPlaceholderRegex.FindAllStringSubmatch(string(rendered), -1) {
+			problems = append(problems, fmt.Sprintf("Caddyfile template uses unknown variable %s", match[1]))
+		}
+
+		if adaptErr := adaptCaddyfile(rendered); adaptErr != nil {
+			problems = append(problems, fmt.Sprintf("Rendered Caddyfile is not valid: %s", adaptErr.Error()))
+		}
+	}
+
+	if !directoryExists(FilesTemplatePath(envConfig, programType)) {
+		problems = append(problems, fmt.Sprintf("Files template %s does not exist", FilesTemplatePath(envConfig, programType)))
+	} else if programType.Base() == utils.ProgramTypeApp {
+		if _, err = site.RenderSystemdUnit(envConfig, SystemdSettings{User: "www-data"}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			problems = append(problems, fmt.Sprintf("%s does not render: %s", SystemdUnitTemplateName, err.Error()))
+		}
+	}
+
+	return problems
+}
+
+// adaptCaddyfile runs caddy adapt on the content. Missing caddy is not an error.
+func adaptCaddyfile(content []byte) error {
+	if _, err := exec.LookPath("caddy"); err != nil {
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "cdm-lint-*.Caddyfile")
+	if err != nil {
+		return err
+	}
+	defer func(name string) {
+		_ = os.Remove(name)
+	}(file.Name())
+
+	if _, err = file.Write(content); err != nil {
+		return err
+	}
+	_ = file.Close()
+
+	out, err := exec.Command("caddy", "adapt", "--config", file.Name(), "--adapter", "caddyfile").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
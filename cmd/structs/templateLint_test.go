@@ -0,0 +1,50 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestScaffoldAndLintTemplates(t *testing.T) {
+	envConfig := utils.EnvironmentConfig{CaddySites: t.TempDir(), ServerFiles: t.TempDir()}
+	if err := os.MkdirAll(path.Join(envConfig.CaddySites, "sites-all"), 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, programType := range []utils.ProgramType{utils.ProgramTypeApp, utils.ProgramTypePhp, utils.ProgramTypeHtml} {
+		if problems := LintTemplates(envConfig, programType); len(problems) != 2 {
+			t.Errorf("Expected both templates of %s to be missing, got %v", programType, problems)
+		}
+
+		created, err := ScaffoldTemplates(envConfig, programType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(created) != 2 {
+			t.Errorf("Expected 2 files created for %s, got %v", programType, created)
+		}
+
+		if problems := LintTemplates(envConfig, programType); len(problems) != 0 {
+			t.Errorf("Scaffolded templates of %s should pass, got %v", programType, problems)
+		}
+
+		// Existing templates are kept
+		if created, _ = ScaffoldTemplates(envConfig, programType); len(created) != 0 {
+			t.Errorf("Expected nothing created again for %s, got %v", programType, created)
+		}
+	}
+
+	broken := "$SITE_ADDRESS {\n\troot * $FILES_ROOT\n\theader X-Env {$ENV}\n\trespond $GREETING\n}\n"
+	if err := ioutil.WriteFile(CaddyfileTemplatePath(envConfig, utils.ProgramTypeHtml), []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems := LintTemplates(envConfig, utils.ProgramTypeHtml)
+	if len(problems) != 1 || problems[0] != "Caddyfile template uses unknown variable $GREETING" {
+		t.Errorf("Expected unknown variable $GREETING, got %v", problems)
+	}
+}
@@ -0,0 +1,103 @@
+package structs
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// Starting points of templates created by cdm template new, by base type.
+var scaffoldCaddyfiles = map[utils.ProgramType]string{
+	utils.ProgramTypeApp: `{{.SiteAddress}} {
+	reverse_proxy {{.UpstreamList}}{{if or .LbPolicy .HealthPath}} {
+{{- if .LbPolicy}}
+		lb_policy {{.LbPolicy}}
+{{- end}}
+{{- if .HealthPath}}
+		health_uri {{.HealthPath}}
+		health_interval {{or .HealthEvery "30s"}}
+{{- end}}
+	}{{end}}
+}
+`,
+	utils.ProgramTypePhp: `{{.SiteAddress}} {
+	root * {{.FilesRoot}}/public_html
+	php_fastcgi unix/{{or .PhpSocket "/run/php/php-fpm.sock"}}
+	file_server
+}
+`,
+	utils.ProgramTypeHtml: `{{.SiteAddress}} {
+	root * {{.FilesRoot}}/public_html
+	file_server
+}
+`,
+}
+
+const scaffoldIndex = `<!DOCTYPE html>
+<html>
+<head>
+	<title>${DOM}</title>
+</head>
+<body>
+	<h1>${DOM}</h1>
+</body>
+</html>
+`
+
+const scaffoldSystemdUnit = `[Unit]
+Description={{.DisplayName}}
+After=network.target
+
+[Service]
+Type=simple
+User={{.User}}
+WorkingDirectory={{.WorkingDirectory}}
+Environment=PORT={{.Port}}
+ExecStart={{.WorkingDirectory}}/start
+Restart={{.Restart}}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// ScaffoldTemplates creates both templates of the type, leaving existing ones untouched.
+// Returns paths of created files.
+func ScaffoldTemplates(envConfig utils.EnvironmentConfig, programType utils.ProgramType) ([]string, error) {
+	var created []string
+
+	caddyfilePath := CaddyfileTemplatePath(envConfig, programType)
+	if !fileExists(caddyfilePath) {
+		if err := ioutil.WriteFile(caddyfilePath, []byte(scaffoldCaddyfiles[programType.Base()]), 0644); err != nil {
+			return created, err
+		}
+
+		created = append(created, caddyfilePath)
+	}
+
+	filesPath := FilesTemplatePath(envConfig, programType)
+	if directoryExists(filesPath) {
+		return created, nil
+	}
+
+	files := map[string]string{path.Join("public_html", "index.html"): scaffoldIndex}
+	if programType.Base() == utils.ProgramTypeApp {
+		files = map[string]string{SystemdUnitTemplateName: scaffoldSystemdUnit}
+	}
+
+	for name, content := range files {
+		filePath := path.Join(filesPath, name)
+
+		if err := os.MkdirAll(path.Dir(filePath), 0775); err != nil {
+			return created, err
+		}
+
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			return created, err
+		}
+
+		created = append(created, filePath)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage templates of website types",
+	Long: `Manage templates used by createSite. Every website type has a Caddyfile template (sites-all/template_<type> by default)
+and a files template (SERVER_FILES_DIR/templates/<type> by default), copied to the website's directory.
+
+Caddyfile templates may share their common parts. Snippets from sites-all/snippets are included as {{template "<file name
+without extension>" .}}, and a template starting with "# extends: template_php" renders template_php with its
+{{block "name" .}} parts replaced by the template's own {{define "name"}} parts.`,
+}
+
+// templateEnvironment reads environment variables, exiting if some are missing.
+func templateEnvironment() utils.EnvironmentConfig {
+	envConfig := utils.EnvironmentConfig{}
+
+	if ok, missing := envConfig.ReadEnvironments(); !ok {
+		println("You are missing a required environment variable ", missing)
+		os.Exit(ExitUsage)
+	}
+
+	return envConfig
+}
+
+// templateTypes resolves type arguments, or returns all types if there are none.
+func templateTypes(args []string) []utils.ProgramType {
+	var types []utils.ProgramType
+
+	if len(args) == 0 {
+		for _, definition := range utils.ProgramTypes() {
+			types = append(types, definition.Name)
+		}
+
+		return types
+	}
+
+	for _, arg := range args {
+		programType, err := utils.GetProgramType(arg)
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitUsage)
+		}
+
+		types = append(types, programType)
+	}
+
+	return types
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+}
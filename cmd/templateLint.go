@@ -0,0 +1,59 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// templateLintCmd represents the template lint command
+var templateLintCmd = &cobra.Command{
+	Use:   "lint [website type]...",
+	Short: "Check templates by rendering them with sample data",
+	Long: `Render templates of the given website types (all types by default) for a sample website, reporting missing templates,
+template errors and unknown $VARIABLES. If caddy is installed, the rendered Caddyfile is also checked with caddy adapt.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := templateEnvironment()
+		failed := 0
+
+		for _, programType := range templateTypes(args) {
+			problems := structs.LintTemplates(envConfig, programType)
+
+			if len(problems) == 0 {
+				println(fmt.Sprintf("[%s] OK", strings.ToLower(string(programType))))
+				continue
+			}
+
+			failed++
+			for _, problem := range problems {
+				println(fmt.Sprintf("[%s] %s", strings.ToLower(string(programType)), problem))
+			}
+		}
+
+		if failed > 0 {
+			os.Exit(ExitTemplateMissing)
+		}
+	},
+}
+
+func init() {
+	templateCmd.AddCommand(templateLintCmd)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// templateListCmd represents the template list command
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List website types and their templates",
+	Long: `List website types with their Caddyfile and files templates, showing which of them exist.
+Templates in sites-all which do not belong to any type are listed as well.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := templateEnvironment()
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(table, "TYPE\tCADDYFILE\tFILES\tCADDYFILE TEMPLATE\tFILES TEMPLATE")
+
+		used := map[string]bool{}
+
+		for _, programType := range templateTypes(nil) {
+			caddyfilePath := structs.CaddyfileTemplatePath(envConfig, programType)
+			filesPath := structs.FilesTemplatePath(envConfig, programType)
+			used[path.Base(caddyfilePath)] = true
+
+			_, caddyfileErr := os.Stat(caddyfilePath)
+			files, filesErr := os.Stat(filesPath)
+
+			_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", strings.ToLower(string(programType)), yesNo(caddyfileErr == nil), yesNo(filesErr == nil && files.IsDir()), caddyfilePath, filesPath)
+		}
+
+		entries, _ := ioutil.ReadDir(path.Join(envConfig.CaddySites, "sites-all"))
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "template_") && !used[entry.Name()] {
+				_, _ = fmt.Fprintf(table, "-\tyes\t-\t%s\t-\n", path.Join(envConfig.CaddySites, "sites-all", entry.Name()))
+			}
+		}
+
+		_ = table.Flush()
+	},
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func init() {
+	templateCmd.AddCommand(templateListCmd)
+}
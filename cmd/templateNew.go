@@ -0,0 +1,56 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// templateNewCmd represents the template new command
+var templateNewCmd = &cobra.Command{
+	Use:   "new <website type>",
+	Short: "Scaffold templates of a website type",
+	Long: `Create a Caddyfile template and a files template for the website type, based on what its base type needs.
+Existing templates are left untouched. Types other than the built-in ones have to be declared under types in the config file first.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := templateEnvironment()
+		programType := templateTypes(args)[0]
+
+		created, err := structs.ScaffoldTemplates(envConfig, programType)
+		for _, file := range created {
+			println(fmt.Sprintf("[%s] Created %s", strings.ToLower(string(programType)), file))
+		}
+
+		if err != nil {
+			println(err.Error())
+			os.Exit(ExitGeneric)
+		}
+
+		if len(created) == 0 {
+			println(fmt.Sprintf("[%s] Both templates already exist", strings.ToLower(string(programType))))
+		}
+	},
+}
+
+func init() {
+	templateCmd.AddCommand(templateNewCmd)
+}
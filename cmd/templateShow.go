@@ -0,0 +1,81 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/spf13/cobra"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// templateShowCmd represents the template show command
+var templateShowCmd = &cobra.Command{
+	Use:   "show <website type>",
+	Short: "Print templates of a website type",
+	Long:  `Print the Caddyfile template of the website type, followed by the list of files in its files template.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := templateEnvironment()
+		programType := templateTypes(args)[0]
+
+		caddyfilePath := structs.CaddyfileTemplatePath(envConfig, programType)
+		filesPath := structs.FilesTemplatePath(envConfig, programType)
+
+		fmt.Printf("# Caddyfile template: %s\n", caddyfilePath)
+		content, caddyfileErr := ioutil.ReadFile(caddyfilePath)
+		if caddyfileErr != nil {
+			fmt.Printf("# (%s)\n", caddyfileErr.Error())
+		} else {
+			fmt.Print(string(content))
+		}
+
+		fmt.Printf("\n# Files template: %s\n", filesPath)
+		err := filepath.WalkDir(filesPath, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relative, _ := filepath.Rel(filesPath, filePath)
+			if relative == "." {
+				return nil
+			}
+
+			if entry.IsDir() {
+				relative += string(filepath.Separator)
+			}
+
+			fmt.Println(relative)
+
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("# (%s)\n", err.Error())
+		}
+
+		if err != nil || caddyfileErr != nil {
+			os.Exit(ExitTemplateMissing)
+		}
+	},
+}
+
+func init() {
+	templateCmd.AddCommand(templateShowCmd)
+}
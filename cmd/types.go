@@ -0,0 +1,34 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// typesCmd represents the types command
+var typesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "Inspect available website types",
+	Long: `Inspect website types - the built-in application, php and html, and the ones declared under types in the config file.
+A declared type names its Caddyfile template (in sites-all) and files template (in SERVER_FILES_DIR/templates),
+the built-in type whose behaviour it shares, required template variables and default values.`,
+}
+
+func init() {
+	rootCmd.AddCommand(typesCmd)
+}
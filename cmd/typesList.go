@@ -0,0 +1,82 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// typesListCmd represents the types list command
+var typesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available website types",
+	Long: `List website types with their aliases and templates. If CADDY_SITES_DIR and SERVER_FILES_DIR are set,
+templates which do not exist are marked.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+		checkTemplates, _ := envConfig.ReadEnvironments()
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(table, "TYPE\tBASE\tALIASES\tCADDYFILE TEMPLATE\tFILES TEMPLATE\tREQUIRED VARS\tDESCRIPTION")
+
+		for _, definition := range utils.ProgramTypes() {
+			caddyfileTemplate := definition.CaddyfileTemplateName()
+			filesTemplate := definition.FilesTemplateName()
+
+			if checkTemplates {
+				if _, err := os.Stat(path.Join(envConfig.CaddySites, "sites-all", caddyfileTemplate)); err != nil {
+					caddyfileTemplate += " (missing)"
+				}
+
+				if _, err := os.Stat(path.Join(envConfig.ServerFiles, "templates", filesTemplate)); err != nil {
+					filesTemplate += " (missing)"
+				}
+			}
+
+			_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				strings.ToLower(string(definition.Name)),
+				strings.ToLower(string(definition.Base)),
+				orDash(strings.Join(definition.Aliases, ", ")),
+				caddyfileTemplate,
+				filesTemplate,
+				orDash(strings.Join(definition.RequiredVars, ", ")),
+				definition.Description)
+		}
+
+		_ = table.Flush()
+	},
+}
+
+// orDash fills empty table cells.
+func orDash(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+
+	return value
+}
+
+func init() {
+	typesCmd.AddCommand(typesListCmd)
+}
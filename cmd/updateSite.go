@@ -0,0 +1,211 @@
+/*
+Copyright © 2021 F4 Developer (Stanisław Kowański) <skowanski@f4dev.me>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kovansky/caddyDomainManager/cmd/structs"
+	"github.com/kovansky/caddyDomainManager/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var (
+	updateType    string
+	updatePort    string
+	updateOverlay bool
+)
+
+// updateSiteCmd represents the updateSite command
+var updateSiteCmd = &cobra.Command{
+	Use:   "updateSite <domain name>",
+	Short: "Change type, port, TLS options or hardening preset of an existing website",
+	Long: `Change the type, application port, TLS options or hardening preset of an existing website. The Caddyfile is rendered again from the new type's template,
+validated with the whole Caddy config (the previous one is kept if it is not valid), and Caddy is reloaded.
+With --overlay, files of the new type's template missing in the website's directory are copied there - existing files are never overwritten.
+A php-fpm pool is created or removed when the website becomes or stops being a PHP one, and a systemd unit is installed for new applications.
+An existing unit is rendered again, i.e. with the new port, and its service restarted if it is running.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		envConfig := utils.EnvironmentConfig{}
+
+		if ok, missing := envConfig.ReadEnvironments(); !ok {
+			println("You are missing a required environment variable ", missing)
+			os.Exit(ExitUsage)
+		}
+
+		domain := domainArg(args[0])
+		record := lookupSite(envConfig, domain)
+		site := structs.SiteConfigFromRecord(record)
+
+		var undo rollback
+
+		fail := func(err error) {
+			println(err.Error())
+			undo.run(domain)
+			os.Exit(exitCode(err))
+		}
+
+		if len(updateType) > 0 {
+			programType, err := utils.GetProgramType(updateType)
+			if err != nil {
+				fail(withExitCode(ExitUsage, err))
+			}
+
+			site.ChangeType(programType)
+		}
+
+		isApp := site.Type.Base() == utils.ProgramTypeApp
+
+		if cmd.Flags().Changed("port") {
+			if !isApp {
+				fail(withExitCode(ExitUsage, fmt.Errorf("only application websites have a port, %s is %s", domain, strings.ToLower(string(site.Type)))))
+			}
+
+			port, err := sitePort(envConfig, updatePort)
+			if err != nil {
+				fail(err)
+			}
+
+			site.Port = port
+			if len(site.Upstreams) > 0 {
+				println(fmt.Sprintf("Warning: [%s] website proxies to its upstreams, the port is only recorded", domain))
+			}
+		}
+
+		// Options alone would be silently ignored, the whole tls setting is replaced at once
+		for _, option := range []string{"cert", "key", "acme-email", "acme-ca", "acme-dns"} {
+			if cmd.Flags().Changed(option) && !cmd.Flags().Changed("tls") {
+				fail(withExitCode(ExitUsage, fmt.Errorf("--%s requires --tls", option)))
+			}
+		}
+
+		if cmd.Flags().Changed("tls") {
+			tls, err := siteTls(tlsMode, tlsCert, tlsKey, tlsEmail, tlsCa, tlsDns)
+			if err != nil {
+				fail(err)
+			}
+
+			site.Tls = tls
+		}
+
+		if cmd.Flags().Changed("preset") {
+			preset, err := sitePreset(presetName)
+			if err != nil {
+				fail(err)
+			}
+
+			site.Preset = preset
+		}
+
+		if err := site.ApplyTypeDefaults(); err != nil {
+			fail(withExitCode(ExitUsage, err))
+		}
+
+		// New applications get a port of their own, unless the type declares one
+		if isApp && site.Port == 0 && len(site.Upstreams) == 0 {
+			port, err := sitePort(envConfig, PortAuto)
+			if err != nil {
+				fail(err)
+			}
+
+			site.Port = port
+		}
+
+		if !isApp {
+			site.Port, site.Upstreams, site.LbPolicy, site.HealthPath, site.HealthEvery = 0, nil, "", "", ""
+		}
+
+		if updateOverlay {
+			created, err := site.OverlayFileStructure(envConfig)
+			if os.IsNotExist(err) {
+				println(fmt.Sprintf("Warning: template directory for %s type does not exist; omitting files overlay.", strings.ToLower(string(site.Type))))
+			} else if err != nil {
+				fail(withExitCode(ExitFileStructure, err))
+			}
+
+			for _, file := range created {
+				println(fmt.Sprintf("[%s] Copied %s", domain, file))
+			}
+
+			// Directories come before their files, so files are removed first
+			undo.add(func() error {
+				for i := len(created) - 1; i >= 0; i-- {
+					if err := os.Remove(created[i]); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+
+				return nil
+			})
+
+			if err = secureSitePaths(site, created); err != nil {
+				fail(err)
+			}
+		}
+
+		previous, err := switchSiteRuntime(envConfig, &site, &undo)
+		if err != nil {
+			fail(err)
+		}
+
+		// An application keeping its unit gets it rendered again, i.e. with the new port
+		if isApp && len(record.Service) > 0 {
+			if err = updateSiteService(envConfig, site, structs.SiteConfigFromRecord(record), &undo); err != nil {
+				fail(err)
+			}
+		}
+
+		changed, err := rerenderSite(envConfig, &site, record)
+		if err != nil {
+			fail(err)
+		}
+
+		// The website is updated, steps which follow are not undone
+		undo = nil
+
+		removeReplacedPhpPool(previous, site)
+
+		if len(site.Tls.Mode) > 0 {
+			println(fmt.Sprintf("[%s] Website is now %s, with %s tls", domain, strings.ToLower(string(site.Type)), site.Tls.Mode))
+		} else {
+			println(fmt.Sprintf("[%s] Website is now %s", domain, strings.ToLower(string(site.Type))))
+		}
+
+		if changed && site.IsEnabled(envConfig) {
+			if ok, err := site.ReloadCaddy(envConfig); !ok {
+				fail(withExitCode(ExitCaddyReload, err))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateSiteCmd)
+
+	updateSiteCmd.Flags().StringVar(&updateType, "type", "", "New type of the website")
+	updateSiteCmd.Flags().StringVarP(&updatePort, "port", "p", "", "New port of the application behind the proxy, or 'auto' to pick a free one")
+	updateSiteCmd.Flags().StringVar(&tlsMode, "tls", "", "Certificate of the website: 'internal' (Caddy's internal CA), 'acme', 'custom' or 'none' to leave it to the template")
+	updateSiteCmd.Flags().StringVar(&tlsCert, "cert", "", "Certificate file (absolute path), for --tls custom")
+	updateSiteCmd.Flags().StringVar(&tlsKey, "key", "", "Key file (absolute path), for --tls custom")
+	updateSiteCmd.Flags().StringVar(&tlsEmail, "acme-email", "", "ACME account email, for --tls acme. Optional, tls.email from config by default.")
+	updateSiteCmd.Flags().StringVar(&tlsCa, "acme-ca", "", "ACME directory URL, for --tls acme. Optional, tls.ca from config or Caddy's default.")
+	updateSiteCmd.Flags().StringVar(&tlsDns, "acme-dns", "", "DNS provider solving the DNS challenge, i.e. 'cloudflare {env.CF_API_TOKEN}', for --tls acme")
+	updateSiteCmd.Flags().StringVar(&presetName, "preset", "", "Hardening preset of the website, i.e. 'basic' or 'strict', or 'none' to remove it")
+	updateSiteCmd.Flags().BoolVar(&updateOverlay, "overlay", false, "Copy files of the new type's template which are missing in the website's directory")
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsafeArchivePath = errors.New("archive entry points outside of the destination")
+
+// TarDirectory adds the whole directory tree to the archive under prefix. Entries for which
+// skip returns true (given a path relative to root) are left out.
+func TarDirectory(tw *tar.Writer, root, prefix string, skip func(relative string) bool) error {
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+
+		if relative != "." && skip != nil && skip(relative) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		header.Name = path.Join(prefix, filepath.ToSlash(relative))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		source, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer func(source *os.File) {
+			_ = source.Close()
+		}(source)
+
+		_, err = io.Copy(tw, source)
+
+		return err
+	})
+}
+
+// TarFile adds a single file to the archive.
+func TarFile(tw *tar.Writer, name, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	source, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func(source *os.File) {
+		_ = source.Close()
+	}(source)
+
+	_, err = io.Copy(tw, source)
+
+	return err
+}
+
+// ExtractTar unpacks the archive into destination, refusing entries that would escape it: paths pointing outside,
+// symbolic links leading out of their top-level directory (so files/ can't reach database.sql or another site)
+// and entries which would be written through a symbolic link.
+func ExtractTar(r io.Reader, destination string) error {
+	tr := tar.NewReader(r)
+	destination = filepath.Clean(destination)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, filepath.FromSlash(header.Name))
+		if !isWithin(destination, target) {
+			return ErrUnsafeArchivePath
+		}
+
+		if err = checkNoSymlinks(destination, target); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, os.FileMode(header.Mode).Perm())
+		case tar.TypeSymlink:
+			linked := filepath.FromSlash(header.Linkname)
+			if filepath.IsAbs(linked) || !isWithin(topDirectory(destination, target), filepath.Join(filepath.Dir(target), linked)) {
+				return ErrUnsafeArchivePath
+			}
+
+			err = os.Symlink(header.Linkname, target)
+		case tar.TypeReg:
+			err = extractFile(tr, target, os.FileMode(header.Mode).Perm())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isWithin tells whether the cleaned path is the directory itself or lies inside of it.
+func isWithin(directory, file string) bool {
+	file = filepath.Clean(file)
+
+	return file == directory || strings.HasPrefix(file, directory+string(os.PathSeparator))
+}
+
+// topDirectory returns the top-level directory of the archive holding the target, destination itself for top-level entries.
+func topDirectory(destination, target string) string {
+	relative, err := filepath.Rel(destination, target)
+	if err != nil {
+		return destination
+	}
+
+	parts := strings.SplitN(relative, string(os.PathSeparator), 2)
+	if len(parts) < 2 {
+		return destination
+	}
+
+	return filepath.Join(destination, parts[0])
+}
+
+// checkNoSymlinks returns ErrUnsafeArchivePath if the target or any of its parents below destination
+// is an existing symbolic link, as writing there would follow the link.
+func checkNoSymlinks(destination, target string) error {
+	for current := target; current != destination; current = filepath.Dir(current) {
+		info, err := os.Lstat(current)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return ErrUnsafeArchivePath
+		} else if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0775); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	_, err = io.Copy(file, r)
+
+	return err
+}
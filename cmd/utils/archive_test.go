@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTarDirectoryExtractTar(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"index.html":       "<h1>Hello</h1>",
+		"assets/style.css": "body {}",
+		"skipped/file.txt": "skipped",
+	}
+
+	for name, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(root, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink("assets/style.css", path.Join(root, "style.css")); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+
+	err := TarDirectory(tw, root, "files", func(relative string) bool {
+		return relative == "skipped"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := t.TempDir()
+	if err = ExtractTar(&archive, destination); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := map[string]string{
+		"index.html":       files["index.html"],
+		"assets/style.css": files["assets/style.css"],
+		"style.css":        files["assets/style.css"],
+	}
+
+	for name, expected := range extracted {
+		content, err := ioutil.ReadFile(path.Join(destination, "files", name))
+		if err != nil || string(content) != expected {
+			t.Errorf("File %s extracted incorrectly, expected %s, got %s (%v)", name, expected, content, err)
+		}
+	}
+
+	if link, err := os.Readlink(path.Join(destination, "files", "style.css")); err != nil || link != "assets/style.css" {
+		t.Errorf("Symlink extracted incorrectly, expected assets/style.css, got %s (%v)", link, err)
+	}
+
+	if _, err = os.Stat(path.Join(destination, "files", "skipped")); !os.IsNotExist(err) {
+		t.Errorf("Skipped directory extracted, expected it to be left out, got %v", err)
+	}
+}
+
+func TestExtractTarUnsafe(t *testing.T) {
+	type entry struct {
+		name     string
+		linkname string // Symbolic link if set, regular file otherwise
+	}
+
+	tables := []struct {
+		name    string
+		entries []entry
+	}{
+		{"parent traversal", []entry{{name: "../evil.txt"}}},
+		{"nested traversal", []entry{{name: "files/../../evil.txt"}}},
+		{"absolute symlink", []entry{{name: "link", linkname: "/etc/passwd"}}},
+		{"escaping symlink", []entry{{name: "files/link", linkname: "../../outside"}}},
+		{"symlink to another top-level entry", []entry{{name: "files/public_html/x", linkname: "../../other.com/database_info.txt"}}},
+		{"symlink to the dump", []entry{{name: "files/link", linkname: "../database.sql"}}},
+		{"write through symlink", []entry{{name: "link", linkname: "."}, {name: "link/evil.txt"}}},
+		{"overwrite symlink", []entry{{name: "link", linkname: "target.txt"}, {name: "link"}}},
+	}
+
+	for _, table := range tables {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+
+		for _, e := range table.entries {
+			header := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len("evil"))}
+			if len(e.linkname) > 0 {
+				header = &tar.Header{Name: e.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: e.linkname}
+			}
+
+			if err := tw.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+			if header.Typeflag == tar.TypeReg {
+				if _, err := tw.Write([]byte("evil")); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		destination := path.Join(t.TempDir(), "destination")
+		if err := os.Mkdir(destination, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ExtractTar(&archive, destination); !errors.Is(err, ErrUnsafeArchivePath) {
+			t.Errorf("Archive with %s extracted, expected ErrUnsafeArchivePath, got %v", table.name, err)
+		}
+
+		if _, err := os.Stat(path.Join(path.Dir(destination), "evil.txt")); !os.IsNotExist(err) {
+			t.Errorf("Archive with %s wrote outside of the destination", table.name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+// BackupFileName builds a name like example.com-20211119-153000.mysql.gz, which sorts chronologically.
+func BackupFileName(domain string, dbType DatabaseType, at time.Time) string {
+	return fmt.Sprintf("%s-%s.%s.gz", domain, at.Format(backupTimeFormat), strings.ToLower(string(dbType)))
+}
+
+// PruneBackups removes the oldest backups of the domain, so that only keep newest remain.
+// Keep equal to 0 disables the retention.
+func PruneBackups(directory, domain string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isBackupOf(entry.Name(), domain) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	sort.Strings(backups)
+
+	removed := backups[:len(backups)-keep]
+	for _, name := range removed {
+		if err = os.Remove(path.Join(directory, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+func isBackupOf(name, domain string) bool {
+	if !strings.HasPrefix(name, domain+"-") || !strings.HasSuffix(name, ".gz") {
+		return false
+	}
+
+	// Make sure example.com does not match backups of example.com-staging.pl
+	stamp := strings.TrimPrefix(name, domain+"-")
+	if len(stamp) < len(backupTimeFormat) {
+		return false
+	}
+
+	_, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)])
+
+	return err == nil
+}
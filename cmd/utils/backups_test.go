@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPruneBackups(t *testing.T) {
+	directory := t.TempDir()
+	start := time.Date(2021, 11, 19, 15, 30, 0, 0, time.UTC)
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		names = append(names, BackupFileName("example.com", DatabaseMysql, start.Add(time.Duration(i)*time.Hour)))
+	}
+	// Backups of other sites must survive
+	names = append(names, BackupFileName("example.com-staging.pl", DatabaseMysql, start), "notes.txt")
+
+	for _, name := range names {
+		if err := ioutil.WriteFile(path.Join(directory, name), []byte{}, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneBackups(directory, "example.com", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(removed, names[:2]) {
+		t.Errorf("Removed wrong backups, expected %s, got %s", names[:2], removed)
+	}
+
+	left, _ := ioutil.ReadDir(directory)
+	if len(left) != 4 {
+		t.Errorf("Expected 4 files left, got %d", len(left))
+	}
+}
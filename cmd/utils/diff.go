@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+	a, b int // Line indexes in both versions, before the line
+}
+
+func splitLines(content string) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// UnifiedDiff compares two versions line by line, like diff -u with context lines around changes.
+// Returns an empty string if they are equal.
+func UnifiedDiff(fromName, toName, from, to string, context int) string {
+	a, b := splitLines(from), splitLines(to)
+
+	// Longest common subsequence of lines, lcs[i][j] is for a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// Hunk spans changes closer to each other than two contexts
+		first := start - context
+		if first < 0 {
+			first = 0
+		}
+
+		last := start
+		for k := start; k < len(lines) && k <= last+2*context; k++ {
+			if lines[k].kind != ' ' {
+				last = k
+			}
+		}
+
+		end := last + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if out.Len() == 0 {
+			_, _ = fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		fromCount, toCount := 0, 0
+		for _, line := range lines[first:end] {
+			if line.kind != '+' {
+				fromCount++
+			}
+			if line.kind != '-' {
+				toCount++
+			}
+		}
+
+		_, _ = fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lines[first].a, fromCount), hunkRange(lines[first].b, toCount))
+
+		for _, line := range lines[first:end] {
+			_, _ = fmt.Fprintf(&out, "%c%s\n", line.kind, line.text)
+		}
+
+		start = end
+	}
+
+	return out.String()
+}
+
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%d", index+1)
+	}
+
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tables := []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"example.com {\n\troot * /srv\n\tencode gzip\n}\n",
+			"example.com {\n\troot * /srv\n\tencode zstd gzip\n}\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n example.com {\n \troot * /srv\n-\tencode gzip\n+\tencode zstd gzip\n }\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,4 +8,3 @@\n 7\n 8\n 9\n-10\n",
+		},
+		{"", "a\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+	}
+
+	for _, table := range tables {
+		if diff := UnifiedDiff("old", "new", table.from, table.to, 3); diff != table.expected {
+			t.Errorf("Diff of %q and %q incorrect, expected %q, got %q", table.from, table.to, table.expected, diff)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidDomain = errors.New("invalid domain name")
+
+const acePrefix = "xn--"
+
+// NormalizeDomain validates the domain name per RFC 1123 and returns its ASCII form, used for Caddy
+// and the filesystem, along with the Unicode form for display. Internationalized names may be given
+// in either form, i.e. żółw.pl or xn--w-uga1v8h.pl.
+//
+// Internationalized labels are checked against a subset of IDNA 2008: lowercase, NFC-normalized letters,
+// digits and hyphens, with combining marks anywhere but first. Symbols, punctuation and emoji are refused.
+// The full IDNA property table and its contextual rules (i.e. for joiners or mixed scripts) are not applied.
+func NormalizeDomain(name string) (ascii string, display string, err error) {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	name = norm.NFC.String(strings.ToLower(name))
+
+	if len(name) == 0 {
+		return "", "", fmt.Errorf("%w: domain is empty", ErrInvalidDomain)
+	}
+
+	labels := strings.Split(name, ".")
+	asciiLabels := make([]string, len(labels))
+	displayLabels := make([]string, len(labels))
+
+	for i, label := range labels {
+		if isAscii(label) {
+			asciiLabels[i] = label
+			displayLabels[i] = label
+
+			if strings.HasPrefix(label, acePrefix) {
+				decoded, err := PunycodeDecode(label[len(acePrefix):])
+				if err != nil || isAscii(decoded) {
+					return "", "", fmt.Errorf("%w: label %q is not correct punycode", ErrInvalidDomain, label)
+				}
+
+				// Only the canonical form is accepted, so one name can't be written two ways
+				if norm.NFC.String(strings.ToLower(decoded)) != decoded {
+					return "", "", fmt.Errorf("%w: label %q does not decode to a normalized name", ErrInvalidDomain, label)
+				}
+
+				if err = validateUnicodeLabel(decoded); err != nil {
+					return "", "", err
+				}
+
+				displayLabels[i] = decoded
+			}
+		} else {
+			if err := validateUnicodeLabel(label); err != nil {
+				return "", "", err
+			}
+
+			encoded, err := PunycodeEncode(label)
+			if err != nil {
+				return "", "", fmt.Errorf("%w: label %q cannot be encoded", ErrInvalidDomain, label)
+			}
+
+			asciiLabels[i] = acePrefix + encoded
+			displayLabels[i] = label
+		}
+
+		if err := validateLabel(asciiLabels[i]); err != nil {
+			return "", "", err
+		}
+	}
+
+	ascii = strings.Join(asciiLabels, ".")
+	if len(ascii) > 253 {
+		return "", "", fmt.Errorf("%w: domain is longer than 253 characters", ErrInvalidDomain)
+	}
+
+	return ascii, strings.Join(displayLabels, "."), nil
+}
+
+// validateLabel checks the RFC 1123 hostname label rules: 1 to 63 letters, digits and hyphens,
+// not starting nor ending with a hyphen.
+func validateLabel(label string) error {
+	if len(label) == 0 {
+		return fmt.Errorf("%w: domain has an empty label", ErrInvalidDomain)
+	}
+
+	if len(label) > 63 {
+		return fmt.Errorf("%w: label %q is longer than 63 characters", ErrInvalidDomain, label)
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("%w: label %q starts or ends with a hyphen", ErrInvalidDomain, label)
+	}
+
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("%w: label %q contains disallowed character %q", ErrInvalidDomain, label, c)
+		}
+	}
+
+	return nil
+}
+
+// validateUnicodeLabel allows letters, digits, hyphens and combining marks, which can't start the label.
+func validateUnicodeLabel(label string) error {
+	for i, c := range label {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-':
+		case unicode.Is(unicode.M, c) && i > 0:
+		default:
+			return fmt.Errorf("%w: label %q contains disallowed character %q", ErrInvalidDomain, label, c)
+		}
+	}
+
+	return nil
+}
+
+func isAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Punycode (RFC 3492) parameters
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+var errPunycodeOverflow = errors.New("punycode overflow")
+
+// PunycodeEncode encodes a single label, without the xn-- prefix.
+func PunycodeEncode(label string) (string, error) {
+	input := []rune(label)
+	output := strings.Builder{}
+
+	for _, r := range input {
+		if r < utf8.RuneSelf {
+			output.WriteRune(r)
+		}
+	}
+
+	basic := output.Len()
+	handled := basic
+	if basic > 0 {
+		output.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+
+	for handled < len(input) {
+		// Find the smallest code point not handled yet
+		m := rune(0x7fffffff)
+		for _, r := range input {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		if int(m-n) > (0x7fffffff-delta)/(handled+1) {
+			return "", errPunycodeOverflow
+		}
+
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range input {
+			if r < n {
+				delta++
+			}
+
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := punycodeThreshold(k, bias)
+				if q < t {
+					break
+				}
+
+				output.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+
+			output.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return output.String(), nil
+}
+
+// PunycodeDecode decodes a single label, given without the xn-- prefix.
+func PunycodeDecode(encoded string) (string, error) {
+	var output []rune
+
+	position := strings.LastIndexByte(encoded, '-')
+	if position > 0 {
+		for _, r := range encoded[:position] {
+			if r >= utf8.RuneSelf {
+				return "", ErrInvalidDomain
+			}
+
+			output = append(output, r)
+		}
+	}
+	position++
+
+	n, i, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+
+	for position < len(encoded) {
+		oldI, w := i, 1
+
+		for k := punycodeBase; ; k += punycodeBase {
+			if position >= len(encoded) {
+				return "", ErrInvalidDomain
+			}
+
+			digit, ok := punycodeValue(encoded[position])
+			position++
+			if !ok {
+				return "", ErrInvalidDomain
+			}
+
+			if digit > (0x7fffffff-i)/w {
+				return "", errPunycodeOverflow
+			}
+
+			i += digit * w
+			t := punycodeThreshold(k, bias)
+			if digit < t {
+				break
+			}
+
+			w *= punycodeBase - t
+		}
+
+		bias = punycodeAdapt(i-oldI, len(output)+1, oldI == 0)
+		n += rune(i / (len(output) + 1))
+		i %= len(output) + 1
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = n
+		i++
+	}
+
+	return string(output), nil
+}
+
+func punycodeThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	default:
+		return k - bias
+	}
+}
+
+func punycodeAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+
+	delta += delta / points
+
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+
+	return byte('0' + d - 26)
+}
+
+func punycodeValue(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tables := []struct {
+		input   string
+		ascii   string
+		display string
+	}{
+		{"Example.COM", "example.com", "example.com"},
+		{"test.example.com.", "test.example.com", "test.example.com"},
+		{"żółw.pl", "xn--w-uga1v8h.pl", "żółw.pl"},
+		{"xn--w-uga1v8h.pl", "xn--w-uga1v8h.pl", "żółw.pl"},
+		{"bücher.example.de", "xn--bcher-kva.example.de", "bücher.example.de"},
+		{"münchen.de", "xn--mnchen-3ya.de", "münchen.de"},
+		{"例え.jp", "xn--r8jz45g.jp", "例え.jp"},
+	}
+
+	for _, table := range tables {
+		ascii, display, err := NormalizeDomain(table.input)
+
+		if err != nil {
+			t.Errorf("Domain %s should be valid, got %s", table.input, err)
+		} else if ascii != table.ascii || display != table.display {
+			t.Errorf("Domain %s normalized incorrectly, expected %s (%s), got %s (%s)", table.input, table.ascii, table.display, ascii, display)
+		}
+	}
+
+	invalid := []string{"", "../evil", "exa mple.com", "example..com", "-example.com", "example.com/x", "a_b.com", "xn--zz.com",
+		// Symbols, punctuation and emoji, written directly or as punycode
+		"i❤.com", "xn--i-7iq.com", "😀.example.com", "a b.com", "a。b.com", "a․b.com", "foo·bar.com",
+		// Leading combining mark, ASCII and uppercase labels in punycode
+		"́a.com", "xn--abc-.com", "xn--mnchen-psa.de", "xn--e28h.com",
+	}
+
+	for _, input := range invalid {
+		if _, _, err := NormalizeDomain(input); !errors.Is(err, ErrInvalidDomain) {
+			t.Errorf("Domain %q should be invalid", input)
+		}
+	}
+}
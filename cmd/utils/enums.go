@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type ProgramType string
 
@@ -10,16 +13,13 @@ const (
 	ProgramTypeHtml             = "HTML"
 )
 
-func GetProgramType(s string) ProgramType {
-	s = strings.ToUpper(s)
-	switch s {
-	case "APP", "APPLICATION", "PROXY":
-		return ProgramTypeApp
-	case "PHP", "WP", "WORDPRESS":
-		return ProgramTypePhp
-	default:
-		return ProgramTypeHtml
+// GetProgramType finds the type by its name or alias, case-insensitively.
+func GetProgramType(s string) (ProgramType, error) {
+	if programType, ok := programTypeNames[strings.ToUpper(strings.TrimSpace(s))]; ok {
+		return programType, nil
 	}
+
+	return "", fmt.Errorf("%w %q, see 'cdm types list'", ErrUnknownProgramType, s)
 }
 
 type DatabaseType string
@@ -1,10 +1,14 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"path"
+)
 
 type EnvironmentConfig struct {
 	CaddySites  string
 	ServerFiles string
+	Registry    string
 }
 
 func (envConfig *EnvironmentConfig) ReadEnvironments() (bool, string) {
@@ -18,6 +22,11 @@ func (envConfig *EnvironmentConfig) ReadEnvironments() (bool, string) {
 		return false, "SERVER_FILES_DIR"
 	}
 
+	// Registry location is optional, by default it lives next to the Caddy sites
+	if ok, envConfig.Registry = getEnvNotEmpty("CDM_REGISTRY_FILE"); !ok {
+		envConfig.Registry = path.Join(envConfig.CaddySites, "sites-registry.json")
+	}
+
 	return true, ""
 }
 
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrNoFreePort = errors.New("no free port in the range")
+
+// Port after a colon, as in reverse_proxy 127.0.0.1:8080, [::1]:3000 or :8080 {, but not inside ::1
+var caddyfilePortRegex = regexp.MustCompile(`(?m)(?:[\w\]]|^|\s):(\d{1,5})\b`)
+
+// ParsePortRange reads ranges like 9000-9999.
+func ParsePortRange(value string) (int, int, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("port range %q should look like 9000-9999", value)
+	}
+
+	first, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("port range %q: %w", value, err)
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("port range %q: %w", value, err)
+	}
+
+	if first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("port range %q is not within 1-65535", value)
+	}
+
+	return first, last, nil
+}
+
+// PortsInCaddyfiles collects ports referenced by site Caddyfiles in the directory, templates excluded.
+func PortsInCaddyfiles(directory string) (map[int]bool, error) {
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := map[int]bool{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), "template_") {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(path.Join(directory, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range caddyfilePortRegex.FindAllStringSubmatch(string(content), -1) {
+			if port, err := strconv.Atoi(match[1]); err == nil {
+				ports[port] = true
+			}
+		}
+	}
+
+	return ports, nil
+}
+
+// PortBound checks whether some process already listens on the local TCP port.
+func PortBound(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+
+	_ = listener.Close()
+
+	return false
+}
+
+// FreePort returns the lowest port of the range which is neither used nor bound.
+func FreePort(first, last int, used map[int]bool, bound func(port int) bool) (int, error) {
+	for port := first; port <= last; port++ {
+		if used[port] || bound(port) {
+			continue
+		}
+
+		return port, nil
+	}
+
+	return 0, fmt.Errorf("%w %d-%d", ErrNoFreePort, first, last)
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tables := []struct {
+		value string
+		first int
+		last  int
+		valid bool
+	}{
+		{"9000-9999", 9000, 9999, true},
+		{" 3000 - 3000 ", 3000, 3000, true},
+		{"9999-9000", 0, 0, false},
+		{"0-100", 0, 0, false},
+		{"9000", 0, 0, false},
+		{"a-b", 0, 0, false},
+	}
+
+	for _, table := range tables {
+		first, last, err := ParsePortRange(table.value)
+
+		if (err == nil) != table.valid {
+			t.Errorf("Range %q validity incorrect, expected %t, got error %v", table.value, table.valid, err)
+		}
+
+		if first != table.first || last != table.last {
+			t.Errorf("Range %q incorrect, expected %d-%d, got %d-%d", table.value, table.first, table.last, first, last)
+		}
+	}
+}
+
+func TestPortsInCaddyfiles(t *testing.T) {
+	directory := t.TempDir()
+
+	files := map[string]string{
+		"app.example.com.Caddyfile": "app.example.com {\n  reverse_proxy 127.0.0.1:9001\n}\n",
+		"api.example.com.Caddyfile": "api.example.com {\n  reverse_proxy localhost:9003 [::1]:9004\n}\n:9005 {\n}\n",
+		"template_application":      "$SITE_ADDRESS {\n  reverse_proxy 127.0.0.1:9002\n}\n",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(path.Join(directory, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ports, err := PortsInCaddyfiles(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ports) != 4 || !ports[9001] || !ports[9003] || !ports[9004] || !ports[9005] {
+		t.Errorf("Wrong ports, expected 9001, 9003, 9004 and 9005, got %v", ports)
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	used := map[int]bool{9000: true, 9002: true}
+	bound := func(port int) bool { return port == 9001 }
+
+	port, err := FreePort(9000, 9005, used, bound)
+	if err != nil || port != 9003 {
+		t.Errorf("Wrong port, expected 9003, got %d (%v)", port, err)
+	}
+
+	if _, err = FreePort(9000, 9002, used, bound); !errors.Is(err, ErrNoFreePort) {
+		t.Errorf("Expected ErrNoFreePort, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProgramTypeDefinition describes a type of websites, the built-in ones or declared under types in .cdm.yaml.
+type ProgramTypeDefinition struct {
+	Name              ProgramType       `mapstructure:"name"`
+	Description       string            `mapstructure:"description"`
+	Aliases           []string          `mapstructure:"aliases"`
+	Base              ProgramType       `mapstructure:"base"`              // Built-in type whose behaviour is used: proxy port, php-fpm pool, systemd unit
+	CaddyfileTemplate string            `mapstructure:"caddyfileTemplate"` // Template in sites-all, template_<name> by default
+	FilesTemplate     string            `mapstructure:"filesTemplate"`     // Directory in SERVER_FILES_DIR/templates, <name> by default
+	RequiredVars      []string          `mapstructure:"requiredVars"`
+	Port              int               `mapstructure:"port"` // Default port of proxy types
+	Vars              map[string]string `mapstructure:"vars"` // Default values of template variables
+}
+
+var ErrUnknownProgramType = errors.New("unknown website type")
+
+var builtinProgramTypes = []ProgramTypeDefinition{
+	{Name: ProgramTypeApp, Base: ProgramTypeApp, Description: "Application behind a reverse proxy", Aliases: []string{"app", "proxy"}},
+	{Name: ProgramTypePhp, Base: ProgramTypePhp, Description: "PHP website", Aliases: []string{"wp", "wordpress"}},
+	{Name: ProgramTypeHtml, Base: ProgramTypeHtml, Description: "Static website", Aliases: []string{"static"}},
+}
+
+var (
+	programTypes     = map[ProgramType]ProgramTypeDefinition{}
+	programTypeNames = map[string]ProgramType{} // Upper case names and aliases
+)
+
+func init() {
+	_ = RegisterProgramTypes(nil)
+}
+
+// RegisterProgramTypes replaces declared types with the built-in ones followed by definitions.
+// A definition named like a built-in type overrides it.
+func RegisterProgramTypes(definitions []ProgramTypeDefinition) error {
+	registered := map[ProgramType]ProgramTypeDefinition{}
+	names := map[string]ProgramType{}
+
+	for i, definition := range append(append([]ProgramTypeDefinition{}, builtinProgramTypes...), definitions...) {
+		definition.Name = ProgramType(strings.ToUpper(strings.TrimSpace(string(definition.Name))))
+		if len(definition.Name) == 0 {
+			return fmt.Errorf("website type #%d has no name", i+1-len(builtinProgramTypes))
+		}
+
+		// Without a base, a type overriding a built-in one keeps its behaviour, other types are static
+		if len(definition.Base) == 0 {
+			definition.Base = ProgramTypeHtml
+
+			if previous, ok := registered[definition.Name]; ok {
+				definition.Base = previous.Base
+			}
+		}
+
+		// Base may be given by an alias or another declared type, i.e. app or node
+		definition.Base = ProgramType(strings.ToUpper(string(definition.Base)))
+		if owner, ok := names[string(definition.Base)]; ok {
+			definition.Base = registered[owner].Base
+		}
+
+		if definition.Base != ProgramTypeApp && definition.Base != ProgramTypePhp && definition.Base != ProgramTypeHtml {
+			return fmt.Errorf("website type %s has incorrect base %q, use 'application', 'php' or 'html'", definition.Name, definition.Base)
+		}
+
+		if strings.ContainsAny(definition.CaddyfileTemplate+definition.FilesTemplate, `/\`) {
+			return fmt.Errorf("templates of website type %s should be names, not paths", definition.Name)
+		}
+
+		// Overriding a built-in type frees its names
+		if previous, ok := registered[definition.Name]; ok {
+			for _, alias := range previous.Aliases {
+				delete(names, strings.ToUpper(alias))
+			}
+		}
+
+		for _, name := range append([]string{string(definition.Name)}, definition.Aliases...) {
+			name = strings.ToUpper(name)
+
+			if owner, ok := names[name]; ok && owner != definition.Name {
+				return fmt.Errorf("website type name %s is used by both %s and %s", strings.ToLower(name), owner, definition.Name)
+			}
+
+			names[name] = definition.Name
+		}
+
+		registered[definition.Name] = definition
+	}
+
+	programTypes = registered
+	programTypeNames = names
+
+	return nil
+}
+
+// ProgramTypes returns all available types, sorted by name.
+func ProgramTypes() []ProgramTypeDefinition {
+	definitions := make([]ProgramTypeDefinition, 0, len(programTypes))
+	for _, definition := range programTypes {
+		definitions = append(definitions, definition)
+	}
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+
+	return definitions
+}
+
+// Definition returns the declaration of the type. Types which are no longer declared behave like static websites
+// with templates named after them.
+func (t ProgramType) Definition() ProgramTypeDefinition {
+	if definition, ok := programTypes[t]; ok {
+		return definition
+	}
+
+	return ProgramTypeDefinition{Name: t, Base: ProgramTypeHtml}
+}
+
+// Base returns the built-in type whose behaviour the type shares.
+func (t ProgramType) Base() ProgramType {
+	return t.Definition().Base
+}
+
+func (definition ProgramTypeDefinition) CaddyfileTemplateName() string {
+	if len(definition.CaddyfileTemplate) > 0 {
+		return definition.CaddyfileTemplate
+	}
+
+	return "template_" + strings.ToLower(string(definition.Name))
+}
+
+func (definition ProgramTypeDefinition) FilesTemplateName() string {
+	if len(definition.FilesTemplate) > 0 {
+		return definition.FilesTemplate
+	}
+
+	return strings.ToLower(string(definition.Name))
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetProgramType(t *testing.T) {
+	defer func() { _ = RegisterProgramTypes(nil) }()
+
+	err := RegisterProgramTypes([]ProgramTypeDefinition{
+		{Name: "node", Base: "app", Aliases: []string{"ssr"}},
+		{Name: "hugo", Aliases: []string{"static-site"}, FilesTemplate: "hugo_public"},
+		{Name: "nuxt", Base: "node"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		name     string
+		expected ProgramType
+		base     ProgramType
+	}{
+		{"app", ProgramTypeApp, ProgramTypeApp},
+		{"WordPress", ProgramTypePhp, ProgramTypePhp},
+		{"html", ProgramTypeHtml, ProgramTypeHtml},
+		{"SSR", "NODE", ProgramTypeApp},
+		{"nuxt", "NUXT", ProgramTypeApp},
+		{"static-site", "HUGO", ProgramTypeHtml},
+		{"htlm", "", ""},
+	}
+
+	for _, table := range tables {
+		programType, err := GetProgramType(table.name)
+
+		if len(table.expected) == 0 {
+			if !errors.Is(err, ErrUnknownProgramType) {
+				t.Errorf("Type %s should be unknown, got %s (%v)", table.name, programType, err)
+			}
+			continue
+		}
+
+		if programType != table.expected || programType.Base() != table.base {
+			t.Errorf("Type %s resolved incorrectly, expected %s (%s), got %s (%s)", table.name, table.expected, table.base, programType, programType.Base())
+		}
+	}
+
+	if name := ProgramType("HUGO").Definition().FilesTemplateName(); name != "hugo_public" {
+		t.Errorf("Files template incorrect, expected hugo_public, got %s", name)
+	}
+
+	if name := ProgramType("NODE").Definition().CaddyfileTemplateName(); name != "template_node" {
+		t.Errorf("Caddyfile template incorrect, expected template_node, got %s", name)
+	}
+}
+
+func TestRegisterProgramTypes(t *testing.T) {
+	defer func() { _ = RegisterProgramTypes(nil) }()
+
+	tables := []struct {
+		definitions []ProgramTypeDefinition
+		valid       bool
+	}{
+		{[]ProgramTypeDefinition{{Name: "php", Aliases: []string{"laravel"}}}, true},
+		{[]ProgramTypeDefinition{{Name: "node", Aliases: []string{"wp"}}}, false},
+		{[]ProgramTypeDefinition{{Name: "node", Base: "python"}}, false},
+		{[]ProgramTypeDefinition{{Name: ""}}, false},
+		{[]ProgramTypeDefinition{{Name: "node", FilesTemplate: "../etc"}}, false},
+	}
+
+	for _, table := range tables {
+		if err := RegisterProgramTypes(table.definitions); (err == nil) != table.valid {
+			t.Errorf("Types %v validity incorrect, expected %t, got error %v", table.definitions, table.valid, err)
+		}
+	}
+}
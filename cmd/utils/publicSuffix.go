@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"golang.org/x/text/unicode/norm"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Snapshot of https://publicsuffix.org/list/public_suffix_list.dat. A newer copy can be used
+// without rebuilding, by pointing the publicSuffixList config key to it.
+//
+//go:embed public_suffix_list.dat
+var embeddedPublicSuffixList string
+
+// PublicSuffixList holds rules of the Public Suffix List, used to find the registrable part of domains
+// like shop.example.co.uk, where the suffix has more than one label.
+type PublicSuffixList struct {
+	rules      map[string]bool
+	wildcards  map[string]bool // *.ck is stored as ck
+	exceptions map[string]bool // !www.ck is stored as www.ck
+}
+
+var (
+	publicSuffixList     *PublicSuffixList
+	publicSuffixListOnce sync.Once
+)
+
+func ParsePublicSuffixList(r io.Reader) (*PublicSuffixList, error) {
+	list := &PublicSuffixList{
+		rules:      map[string]bool{},
+		wildcards:  map[string]bool{},
+		exceptions: map[string]bool{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Rule is the first word of the line, the rest is ignored
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+
+		// Domains are matched in their ASCII form, while the list has internationalized rules in Unicode
+		rule, ok := asciiRule(fields[0])
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			list.exceptions[rule[1:]] = true
+		case strings.HasPrefix(rule, "*."):
+			list.wildcards[rule[2:]] = true
+		default:
+			list.rules[rule] = true
+		}
+	}
+
+	return list, scanner.Err()
+}
+
+// asciiRule lowercases the rule and punycode-encodes its internationalized labels. Rules which can't be encoded are skipped.
+func asciiRule(rule string) (string, bool) {
+	labels := strings.Split(norm.NFC.String(strings.ToLower(rule)), ".")
+
+	for i, label := range labels {
+		if isAscii(label) {
+			continue
+		}
+
+		encoded, err := PunycodeEncode(label)
+		if err != nil {
+			return "", false
+		}
+
+		labels[i] = acePrefix + encoded
+	}
+
+	return strings.Join(labels, "."), true
+}
+
+// LoadPublicSuffixList replaces the embedded list with one read from file.
+func LoadPublicSuffixList(file string) error {
+	content, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func(content *os.File) {
+		_ = content.Close()
+	}(content)
+
+	list, err := ParsePublicSuffixList(content)
+	if err != nil {
+		return err
+	}
+
+	publicSuffixListOnce.Do(func() {})
+	publicSuffixList = list
+
+	return nil
+}
+
+func defaultPublicSuffixList() *PublicSuffixList {
+	publicSuffixListOnce.Do(func() {
+		publicSuffixList, _ = ParsePublicSuffixList(strings.NewReader(embeddedPublicSuffixList))
+	})
+
+	return publicSuffixList
+}
+
+// PublicSuffix returns the longest suffix of the domain matching the list, i.e. co.uk for shop.example.co.uk.
+// Domains with unknown TLD are treated as if their last label was the suffix.
+func (list PublicSuffixList) PublicSuffix(domain string) string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+
+		if list.exceptions[candidate] {
+			return strings.Join(labels[i+1:], ".")
+		}
+
+		if list.rules[candidate] {
+			return candidate
+		}
+
+		if i+1 < len(labels) && list.wildcards[strings.Join(labels[i+1:], ".")] {
+			return candidate
+		}
+	}
+
+	return labels[len(labels)-1]
+}
+
+// RegistrableDomain returns the public suffix with one more label, i.e. example.co.uk for shop.example.co.uk.
+// Empty string is returned when the domain is a public suffix itself.
+func (list PublicSuffixList) RegistrableDomain(domain string) string {
+	domain = strings.ToLower(domain)
+	suffix := list.PublicSuffix(domain)
+
+	if suffix == domain || !strings.HasSuffix(domain, "."+suffix) {
+		return ""
+	}
+
+	rest := strings.TrimSuffix(domain, "."+suffix)
+
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix
+}
+
+// RegistrableDomain uses the embedded (or loaded) Public Suffix List.
+func RegistrableDomain(domain string) string {
+	return defaultPublicSuffixList().RegistrableDomain(domain)
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublicSuffixList_RegistrableDomain(t *testing.T) {
+	list, err := ParsePublicSuffixList(strings.NewReader("// comment\ncom\nuk\nco.uk\n*.ck\n!www.ck\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		input    string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"shop.Example.co.uk", "example.co.uk"},
+		{"co.uk", ""},
+		{"shop.example.ck", "shop.example.ck"},
+		{"example.ck", ""},
+		{"www.ck", "www.ck"},
+		{"a.www.ck", "www.ck"},
+		{"example.unknown", "example.unknown"},
+	}
+
+	for _, table := range tables {
+		if result := list.RegistrableDomain(table.input); result != table.expected {
+			t.Errorf("Registrable domain of %s found incorrectly, expected %q, got %q", table.input, table.expected, result)
+		}
+	}
+}
+
+func TestPublicSuffixList_RegistrableDomainIdn(t *testing.T) {
+	list, err := ParsePublicSuffixList(strings.NewReader("cn\n公司.cn\n香港\n個人.香港\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		input    string
+		expected string
+	}{
+		{"shop.example.公司.cn", "example.公司.cn"},
+		{"a.b.個人.香港", "b.個人.香港"},
+		{"example.香港", "example.香港"},
+		{"example.公司.cn", "example.公司.cn"},
+	}
+
+	for _, table := range tables {
+		// Sites keep their domains in ASCII form
+		input, _, err := NormalizeDomain(table.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, _, err := NormalizeDomain(table.expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result := list.RegistrableDomain(input); result != expected {
+			t.Errorf("Registrable domain of %s found incorrectly, expected %q, got %q", table.input, expected, result)
+		}
+
+		if result := RegistrableDomain(input); result != expected {
+			t.Errorf("Registrable domain of %s found incorrectly with the embedded list, expected %q, got %q", table.input, expected, result)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	SiteDirMode  fs.FileMode = 0750
+	SiteFileMode fs.FileMode = 0640
+	// Directories leading to files roots of nested sites, other users may pass but not list them
+	TraverseDirMode fs.FileMode = 0751
+
+	// Names of site users are the prefix followed by a hash of the domain, so they never match accounts of the system itself
+	systemUserPrefix     = "site_"
+	systemUserHashLength = 12
+)
+
+var (
+	ErrUnknownSystemUser = errors.New("system user does not exist")
+	ErrSystemUserExists  = errors.New("system user already exists")
+)
+
+// SystemUsers manages Linux accounts and file ownership. CommandSystemUsers is the real backend.
+type SystemUsers interface {
+	// Lookup returns ids of the user and its primary group, ErrUnknownSystemUser if there is no such user.
+	Lookup(name string) (uid, gid int, err error)
+	// CreateUser adds a system user without login shell, with a primary group of the same name.
+	CreateUser(name, home string) error
+	// DeleteUser removes the user with its primary group, leaving its files in place.
+	DeleteUser(name string) error
+	// AddToGroup adds an existing user to the supplementary group.
+	AddToGroup(name, group string) error
+	Chown(path string, uid, gid int) error
+}
+
+// CommandSystemUsers manages accounts with useradd and usermod.
+type CommandSystemUsers struct{}
+
+var DefaultSystemUsers SystemUsers = CommandSystemUsers{}
+
+func (CommandSystemUsers) Lookup(name string) (int, int, error) {
+	account, err := user.Lookup(name)
+	if err != nil {
+		if _, ok := err.(user.UnknownUserError); ok {
+			return 0, 0, ErrUnknownSystemUser
+		}
+
+		return 0, 0, err
+	}
+
+	uid, err := strconv.Atoi(account.Uid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	gid, err := strconv.Atoi(account.Gid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uid, gid, nil
+}
+
+func (CommandSystemUsers) CreateUser(name, home string) error {
+	return runCommand("useradd", "--system", "--user-group", "--no-create-home", "--home-dir", home, "--shell", "/usr/sbin/nologin", name)
+}
+
+func (CommandSystemUsers) DeleteUser(name string) error {
+	return runCommand("userdel", name)
+}
+
+func (CommandSystemUsers) AddToGroup(name, group string) error {
+	return runCommand("usermod", "--append", "--groups", group, name)
+}
+
+func (CommandSystemUsers) Chown(path string, uid, gid int) error {
+	return os.Lchown(path, uid, gid)
+}
+
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, out)
+	}
+
+	return nil
+}
+
+// SystemUserName derives the name of the site's user from its (ASCII) domain, i.e. site_a379a6f6eeaf for example.com.
+// Names are short enough for useradd and differ for every domain.
+func SystemUserName(domain string) string {
+	sum := sha256.Sum256([]byte(domain))
+
+	return systemUserPrefix + hex.EncodeToString(sum[:])[:systemUserHashLength]
+}
+
+// CreateSystemUser creates the user and lets the web server user read its files by adding it to the user's group.
+// Existing accounts are never taken over, ErrSystemUserExists is returned for them. Returns ids of the user and its group.
+func CreateSystemUser(users SystemUsers, name, home, webServerUser string) (int, int, error) {
+	_, _, err := users.Lookup(name)
+	if err == nil {
+		return 0, 0, ErrSystemUserExists
+	} else if err != ErrUnknownSystemUser {
+		return 0, 0, err
+	}
+
+	if err = users.CreateUser(name, home); err != nil {
+		return 0, 0, err
+	}
+
+	uid, gid, err := users.Lookup(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(webServerUser) > 0 {
+		if err = users.AddToGroup(webServerUser, name); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// SecureTree hands the whole tree over to the user, with SiteDirMode directories and SiteFileMode files.
+// Symbolic links are chowned, but not followed. Nested roots (files roots of other sites inside the tree) are
+// left to their own users, directories leading to them are only opened for traversal with TraverseDirMode.
+func SecureTree(users SystemUsers, root string, uid, gid int, nested []string) error {
+	skipped := map[string]bool{}
+	traversed := map[string]bool{}
+	for _, nestedRoot := range nested {
+		nestedRoot = filepath.Clean(nestedRoot)
+		skipped[nestedRoot] = true
+
+		for directory := filepath.Dir(nestedRoot); isInsideOrSame(directory, root); directory = filepath.Dir(directory) {
+			traversed[directory] = true
+		}
+	}
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if skipped[filepath.Clean(path)] {
+			return filepath.SkipDir
+		}
+
+		if err = users.Chown(path, uid, gid); err != nil {
+			return err
+		}
+
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			return nil
+		case entry.IsDir() && traversed[filepath.Clean(path)]:
+			return os.Chmod(path, TraverseDirMode)
+		case entry.IsDir():
+			return os.Chmod(path, SiteDirMode)
+		default:
+			return os.Chmod(path, SiteFileMode)
+		}
+	})
+}
+
+// OpenTraversal lets others pass through directories from outer down to the parent of inner, so the user of a
+// site nested inside another site's files root can reach its own files. Other permissions are kept.
+func OpenTraversal(outer, inner string) error {
+	for directory := filepath.Dir(filepath.Clean(inner)); isInsideOrSame(directory, outer); directory = filepath.Dir(directory) {
+		info, err := os.Stat(directory)
+		if err != nil {
+			return err
+		}
+
+		if err = os.Chmod(directory, info.Mode().Perm()|0001); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isInsideOrSame(target, base string) bool {
+	relative, err := filepath.Rel(filepath.Clean(base), filepath.Clean(target))
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, "../")
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeSystemUsers struct {
+	users  map[string]int
+	groups map[string][]string
+	owners map[string]int
+}
+
+func newFakeSystemUsers() *fakeSystemUsers {
+	return &fakeSystemUsers{
+		users:  map[string]int{"caddy": 998},
+		groups: map[string][]string{},
+		owners: map[string]int{},
+	}
+}
+
+func (f *fakeSystemUsers) Lookup(name string) (int, int, error) {
+	id, ok := f.users[name]
+	if !ok {
+		return 0, 0, ErrUnknownSystemUser
+	}
+
+	return id, id, nil
+}
+
+func (f *fakeSystemUsers) CreateUser(name, home string) error {
+	f.users[name] = 1000 + len(f.users)
+	return nil
+}
+
+func (f *fakeSystemUsers) DeleteUser(name string) error {
+	delete(f.users, name)
+	return nil
+}
+
+func (f *fakeSystemUsers) AddToGroup(name, group string) error {
+	f.groups[group] = append(f.groups[group], name)
+	return nil
+}
+
+func (f *fakeSystemUsers) Chown(path string, uid, gid int) error {
+	f.owners[path] = uid
+	return nil
+}
+
+func TestSystemUserName(t *testing.T) {
+	if name := SystemUserName("example.com"); name != "site_a379a6f6eeaf" {
+		t.Errorf("Wrong system user name, expected site_a379a6f6eeaf, got %s", name)
+	}
+
+	// Domains which used to give names of system accounts, or the same name once cut, get names of their own
+	seen := map[string]string{}
+	for _, domain := range []string{"root.com", "caddy.com", "shop.example.com", "shop.example.org", "a-very-long-subdomain-of-the-website.example.com", "a-very-long-subdomain-of-the-website.example.org"} {
+		name := SystemUserName(domain)
+
+		if !strings.HasPrefix(name, systemUserPrefix) || len(name) > 32 {
+			t.Errorf("Wrong system user name of %s, got %s", domain, name)
+		}
+
+		if other, ok := seen[name]; ok {
+			t.Errorf("%s and %s got the same system user name %s", other, domain, name)
+		}
+		seen[name] = domain
+	}
+}
+
+func TestCreateSystemUser(t *testing.T) {
+	users := newFakeSystemUsers()
+
+	uid, gid, err := CreateSystemUser(users, "example", "/srv/example", "caddy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uid != users.users["example"] || gid != uid {
+		t.Errorf("Wrong ids, expected %d, got %d:%d", users.users["example"], uid, gid)
+	}
+
+	if !reflect.DeepEqual(users.groups["example"], []string{"caddy"}) {
+		t.Errorf("Web server user not added to the group, got %s", users.groups["example"])
+	}
+
+	// Existing accounts, of other sites or of the system, are never taken over
+	for _, name := range []string{"example", "caddy"} {
+		if _, _, err = CreateSystemUser(users, name, "/srv/example", ""); err != ErrSystemUserExists {
+			t.Errorf("Expected existing user error for %s, got %v", name, err)
+		}
+	}
+
+	if len(users.users) != 2 || len(users.groups["caddy"]) > 0 {
+		t.Errorf("Existing users should be left untouched, got %v and %v", users.users, users.groups)
+	}
+}
+
+func TestSecureTree(t *testing.T) {
+	root := t.TempDir()
+	users := newFakeSystemUsers()
+
+	if err := os.MkdirAll(path.Join(root, "public_html"), 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(root, "public_html", "index.html"), []byte{}, 0775); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SecureTree(users, root, 1001, 1001, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		path string
+		mode fs.FileMode
+	}{
+		{root, SiteDirMode},
+		{path.Join(root, "public_html"), SiteDirMode},
+		{path.Join(root, "public_html", "index.html"), SiteFileMode},
+	}
+
+	for _, table := range tables {
+		info, err := os.Stat(table.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != table.mode {
+			t.Errorf("Wrong mode of %s, expected %s, got %s", table.path, table.mode, info.Mode().Perm())
+		}
+
+		if users.owners[table.path] != 1001 {
+			t.Errorf("%s not chowned", table.path)
+		}
+	}
+}
+
+func TestSecureTree_NestedSite(t *testing.T) {
+	root := t.TempDir()
+	users := newFakeSystemUsers()
+	nested := path.Join(root, "domains", "api.example.com")
+
+	if err := os.MkdirAll(path.Join(nested, "public_html"), 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(path.Join(root, "public_html"), 0775); err != nil {
+		t.Fatal(err)
+	}
+	for _, directory := range []string{nested, path.Join(nested, "public_html")} {
+		if err := os.Chmod(directory, 0775); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SecureTree(users, root, 1001, 1001, []string{nested}); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		path  string
+		mode  fs.FileMode
+		owner int
+	}{
+		{root, TraverseDirMode, 1001},
+		{path.Join(root, "domains"), TraverseDirMode, 1001},
+		{path.Join(root, "public_html"), SiteDirMode, 1001},
+		{nested, 0775, 0},
+		{path.Join(nested, "public_html"), 0775, 0},
+	}
+
+	for _, table := range tables {
+		info, err := os.Stat(table.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != table.mode {
+			t.Errorf("Wrong mode of %s, expected %s, got %s", table.path, table.mode, info.Mode().Perm())
+		}
+
+		if users.owners[table.path] != table.owner {
+			t.Errorf("Wrong owner of %s, expected %d, got %d", table.path, table.owner, users.owners[table.path])
+		}
+	}
+
+	if err := OpenTraversal(path.Join(root, "public_html"), path.Join(root, "public_html", "child")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path.Join(root, "public_html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != TraverseDirMode {
+		t.Errorf("Directory above a nested site not opened, expected %s, got %s", TraverseDirMode, info.Mode().Perm())
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load balancing policies of Caddy's reverse_proxy. Some of them take arguments, i.e. header X-Tenant.
+var lbPolicies = map[string]bool{
+	"random":               true,
+	"random_choose":        true,
+	"first":                true,
+	"round_robin":          true,
+	"weighted_round_robin": true,
+	"least_conn":           true,
+	"ip_hash":              true,
+	"client_ip_hash":       true,
+	"uri_hash":             true,
+	"query":                true,
+	"header":               true,
+	"cookie":               true,
+}
+
+// NormalizeUpstream validates host:port of an upstream. Hosts are lowercased, IPv6 addresses bracketed.
+func NormalizeUpstream(upstream string) (string, error) {
+	host, portString, err := net.SplitHostPort(strings.TrimSpace(upstream))
+	if err != nil {
+		return "", fmt.Errorf("upstream %s should be host:port: %w", upstream, err)
+	}
+
+	port, err := strconv.Atoi(portString)
+	if err != nil || port < 1 || port > 65535 {
+		return "", fmt.Errorf("upstream %s has incorrect port", upstream)
+	}
+
+	if net.ParseIP(host) == nil {
+		if host, _, err = NormalizeDomain(host); err != nil {
+			return "", fmt.Errorf("upstream %s: %w", upstream, err)
+		}
+	}
+
+	return net.JoinHostPort(host, portString), nil
+}
+
+// ValidLbPolicy checks the policy name, ignoring its arguments. Empty policy leaves Caddy's default.
+func ValidLbPolicy(policy string) bool {
+	fields := strings.Fields(policy)
+
+	return len(fields) == 0 || lbPolicies[fields[0]]
+}
+
+// ValidHealthInterval checks that the interval is a duration Caddy understands, like 10s.
+func ValidHealthInterval(interval string) bool {
+	if len(interval) == 0 {
+		return true
+	}
+
+	duration, err := time.ParseDuration(interval)
+
+	return err == nil && duration > 0
+}
@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestNormalizeUpstream(t *testing.T) {
+	tables := []struct {
+		upstream string
+		expected string
+		valid    bool
+	}{
+		{"127.0.0.1:8080", "127.0.0.1:8080", true},
+		{"App1.Internal:3000", "app1.internal:3000", true},
+		{"[::1]:9000", "[::1]:9000", true},
+		{"localhost", "", false},
+		{"localhost:0", "", false},
+		{"localhost:http", "", false},
+		{"bad_host!:80", "", false},
+	}
+
+	for _, table := range tables {
+		upstream, err := NormalizeUpstream(table.upstream)
+
+		if (err == nil) != table.valid {
+			t.Errorf("Upstream %s validity incorrect, expected %t, got error %v", table.upstream, table.valid, err)
+		}
+
+		if upstream != table.expected {
+			t.Errorf("Upstream %s incorrect, expected %s, got %s", table.upstream, table.expected, upstream)
+		}
+	}
+}
+
+func TestValidLbPolicy(t *testing.T) {
+	tables := []struct {
+		policy string
+		valid  bool
+	}{
+		{"", true},
+		{"round_robin", true},
+		{"header X-Tenant", true},
+		{"fastest", false},
+	}
+
+	for _, table := range tables {
+		if valid := ValidLbPolicy(table.policy); valid != table.valid {
+			t.Errorf("Policy %q validity incorrect, expected %t, got %t", table.policy, table.valid, valid)
+		}
+	}
+}